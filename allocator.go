@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// 号段存储后端
+const (
+	backendMySQL    = "mysql"
+	backendPostgres = "postgres"
+	backendSQLite   = "sqlite"
+	backendMemory   = "memory"
+	backendFile     = "file"
+	backendRaft     = "raft"
+)
+
+// segmentAllocator 号段存储后端，负责为业务标识分配下一个号段
+type segmentAllocator interface {
+	// Backend 后端名称，用于日志和指标
+	Backend() string
+	// Step 返回 bizTag 配置的基础步长，不存在时返回 errBizTagNotFound
+	Step(ctx context.Context, bizTag string) (int64, error)
+	// Reserve 原子地将 bizTag 的 max_id 增加 step，返回增加后的 max_id，即号段 (max_id-step, max_id]
+	Reserve(ctx context.Context, bizTag string, step int64) (int64, error)
+	// Ping 检查后端是否可用
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// sqlAllocator 基于 id_segments 表的号段分配，MySQL、PostgreSQL 和 SQLite 共用
+type sqlAllocator struct {
+	backend    string
+	db         *sql.DB
+	ownsDB     bool   // MySQL 连接池与机器 ID 注册表共享，由 main 统一关闭
+	stepSQL    string // 查询基础步长
+	reserveSQL string // 支持 RETURNING 时一条语句完成分配，为空时在事务中 UPDATE 后 SELECT
+	selectSQL  string
+}
+
+// newMySQLAllocator 使用已打开的 MySQL 连接池
+func newMySQLAllocator(db *sql.DB) *sqlAllocator {
+	return &sqlAllocator{
+		backend:    backendMySQL,
+		db:         db,
+		stepSQL:    "SELECT step FROM id_segments WHERE biz_tag = ?",
+		reserveSQL: "UPDATE id_segments SET max_id = max_id + ? WHERE biz_tag = ?",
+		selectSQL:  "SELECT max_id FROM id_segments WHERE biz_tag = ?",
+	}
+}
+
+// openPostgresAllocator 连接 PostgreSQL，id_segments 表需预先创建
+func openPostgresAllocator(cfg PostgresConfig) (*sqlAllocator, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %v", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return &sqlAllocator{
+		backend:    backendPostgres,
+		db:         db,
+		ownsDB:     true,
+		stepSQL:    "SELECT step FROM id_segments WHERE biz_tag = $1",
+		reserveSQL: "UPDATE id_segments SET max_id = max_id + $1 WHERE biz_tag = $2 RETURNING max_id",
+	}, nil
+}
+
+// sqliteSchema 嵌入式 SQLite 没有单独的部署步骤，打开时自动建表并创建默认业务标识
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS id_segments (
+    biz_tag    TEXT PRIMARY KEY,
+    max_id     INTEGER NOT NULL DEFAULT 0,
+    step       INTEGER NOT NULL DEFAULT 10000,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT OR IGNORE INTO id_segments (biz_tag) VALUES ('default');
+`
+
+// openSQLiteAllocator 打开（不存在时创建）SQLite 数据库文件
+func openSQLiteAllocator(cfg SQLiteConfig) (*sqlAllocator, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create sqlite directory: %v", err)
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)",
+		cfg.Path, cfg.BusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite: %v", err)
+	}
+	// SQLite 同一时间只允许一个写事务，单连接避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init sqlite schema: %v", err)
+	}
+	return &sqlAllocator{
+		backend:    backendSQLite,
+		db:         db,
+		ownsDB:     true,
+		stepSQL:    "SELECT step FROM id_segments WHERE biz_tag = ?",
+		reserveSQL: "UPDATE id_segments SET max_id = max_id + ?, updated_at = CURRENT_TIMESTAMP WHERE biz_tag = ? RETURNING max_id",
+	}, nil
+}
+
+func (a *sqlAllocator) Backend() string {
+	return a.backend
+}
+
+func (a *sqlAllocator) Step(ctx context.Context, bizTag string) (int64, error) {
+	var step int64
+	err := a.db.QueryRowContext(ctx, a.stepSQL, bizTag).Scan(&step)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errBizTagNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query biz_tag %s: %v", bizTag, err)
+	}
+	return step, nil
+}
+
+func (a *sqlAllocator) Reserve(ctx context.Context, bizTag string, step int64) (int64, error) {
+	if a.selectSQL == "" {
+		var newMax int64
+		err := a.db.QueryRowContext(ctx, a.reserveSQL, step, bizTag).Scan(&newMax)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errBizTagNotFound
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to update max_id: %v", err)
+		}
+		return newMax, nil
+	}
+
+	startTime := time.Now()
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// 原子更新 max_id
+	result, err := tx.ExecContext(ctx, a.reserveSQL, step, bizTag)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update max_id: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update max_id: %v", err)
+	}
+	if rowsAffected != 1 {
+		return 0, errBizTagNotFound
+	}
+
+	// 获取新的 max_id
+	var newMax int64
+	if err := tx.QueryRowContext(ctx, a.selectSQL, bizTag).Scan(&newMax); err != nil {
+		return 0, fmt.Errorf("failed to query max_id: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	mysqlQueryDuration.Observe(time.Since(startTime).Seconds())
+	return newMax, nil
+}
+
+func (a *sqlAllocator) Ping(ctx context.Context) error {
+	return a.db.PingContext(ctx)
+}
+
+func (a *sqlAllocator) Close() error {
+	if !a.ownsDB {
+		return nil
+	}
+	return a.db.Close()
+}
+
+// openSegmentAllocator 按配置创建号段存储后端；mysqlDB 为 nil 且后端为 MySQL 时返回 nil，表示不启用 segment 模式
+func openSegmentAllocator(cfg SegmentConfig, mysqlDB *sql.DB) (segmentAllocator, error) {
+	var (
+		alloc segmentAllocator
+		err   error
+	)
+	switch cfg.Backend {
+	case backendMySQL:
+		if mysqlDB == nil {
+			return nil, nil
+		}
+		alloc = newMySQLAllocator(mysqlDB)
+	case backendPostgres:
+		alloc, err = openPostgresAllocator(cfg.Postgres)
+	case backendSQLite:
+		alloc, err = openSQLiteAllocator(cfg.SQLite)
+	case backendFile:
+		alloc, err = openFileAllocator(cfg.File)
+	case backendRaft:
+		alloc, err = openRaftAllocator(cfg.Raft, cfg.MaxStep)
+	case backendMemory:
+		m := newMemoryAllocator(map[string]int64{defaultBizTag: cfg.Memory.Step})
+		m.SetLatency(cfg.Memory.Latency)
+		m.SetFailureRate(cfg.Memory.FailureRate)
+		alloc = m
+	default:
+		return nil, fmt.Errorf("unknown segment backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	mLog.Info("Segment backend ready", zap.String("backend", alloc.Backend()))
+	return alloc, nil
+}
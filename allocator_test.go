@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// openTestSQLite 在临时目录中创建 SQLite 号段后端
+func openTestSQLite(t *testing.T) *sqlAllocator {
+	t.Helper()
+	mLog = zap.NewNop()
+	alloc, err := openSQLiteAllocator(SQLiteConfig{
+		Path:        filepath.Join(t.TempDir(), "segments.db"),
+		BusyTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { alloc.Close() })
+	return alloc
+}
+
+func TestSQLiteAllocatorReserve(t *testing.T) {
+	alloc := openTestSQLite(t)
+	ctx := context.Background()
+
+	step, err := alloc.Step(ctx, defaultBizTag)
+	if err != nil || step != 10000 {
+		t.Fatalf("Step(default) = %d, %v; want 10000", step, err)
+	}
+	if _, err := alloc.Step(ctx, "missing"); !errors.Is(err, errBizTagNotFound) {
+		t.Fatalf("Step(missing) error = %v, want errBizTagNotFound", err)
+	}
+	if _, err := alloc.Reserve(ctx, "missing", 10); !errors.Is(err, errBizTagNotFound) {
+		t.Fatalf("Reserve(missing) error = %v, want errBizTagNotFound", err)
+	}
+
+	// 并发申请的号段互不重叠
+	const workers, rounds = 8, 20
+	var (
+		mu   sync.Mutex
+		maxs = make(map[int64]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				newMax, err := alloc.Reserve(ctx, defaultBizTag, 100)
+				if err != nil {
+					t.Errorf("Reserve: %v", err)
+					return
+				}
+				mu.Lock()
+				maxs[newMax] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(maxs) != workers*rounds {
+		t.Fatalf("got %d distinct segments, want %d", len(maxs), workers*rounds)
+	}
+	for i := int64(1); i <= workers*rounds; i++ {
+		if !maxs[i*100] {
+			t.Fatalf("segment ending at %d missing", i*100)
+		}
+	}
+}
+
+// MySQL 不支持 RETURNING，在事务中 UPDATE 后 SELECT；SQLite 同样支持这两条语句
+func TestMySQLAllocatorReserve(t *testing.T) {
+	alloc := newMySQLAllocator(openTestSQLite(t).db)
+	ctx := context.Background()
+
+	if _, err := alloc.Reserve(ctx, "missing", 10); !errors.Is(err, errBizTagNotFound) {
+		t.Fatalf("Reserve(missing) error = %v, want errBizTagNotFound", err)
+	}
+	for _, want := range []int64{100, 200} {
+		if got, err := alloc.Reserve(ctx, defaultBizTag, 100); err != nil || got != want {
+			t.Fatalf("Reserve(default) = %d, %v; want %d", got, err, want)
+		}
+	}
+}
+
+func TestSegmentNextIDWithSQLite(t *testing.T) {
+	alloc := openTestSQLite(t)
+	if _, err := alloc.db.Exec("UPDATE id_segments SET step = 3 WHERE biz_tag = ?", defaultBizTag); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := NewSegment(context.Background(), alloc, defaultBizTag, SegmentConfig{TargetDuration: time.Hour, MaxStep: 12, PreloadPercent: 10})
+	if err != nil {
+		t.Fatalf("NewSegment: %v", err)
+	}
+	defer segment.Close()
+
+	// 号段消耗远快于 target_duration，步长依次为 3、6、12、12
+	for want := int64(1); want <= 33; want++ {
+		id, err := segment.NextID()
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		if id != want {
+			t.Fatalf("NextID = %d, want %d", id, want)
+		}
+	}
+	segment.mu.Lock()
+	step := segment.step
+	segment.mu.Unlock()
+	if step != 12 {
+		t.Fatalf("step = %d, want 12", step)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// errBufferClosed 服务关闭后不再从 Buffer 取 ID
+var errBufferClosed = errors.New("id buffer is closed")
+
+// IDBuffer 一个模式（segment 模式下为一个业务标识）预生成 ID 的环形缓冲区。
+//
+// 请求从队头取 ID，唯一的后台填充协程在剩余数量不超过低水位时从底层生成器取一批 ID 追加到队尾，
+// 同一时间最多只有一次填充。ID 按生成顺序存放，取出的 ID 保持递增。
+// T 为 ID 类型：snowflake 和 segment 为 int64，ULID 和 UUIDv7 为 [16]byte。
+type IDBuffer[T any] struct {
+	mode     string
+	bizTag   string
+	nextN    func(n int) ([]T, error) // 底层 ID 生成器，一次生成 n 个
+	lowWater int
+
+	mu     sync.Mutex
+	ids    []T   // 环形数组，容量即 buffer.size
+	head   int   // 队头下标
+	count  int   // 剩余 ID 数量
+	err    error // 最近一次填充的错误，填充成功后清除
+	filled chan struct{}
+
+	wake chan struct{} // 通知填充协程，容量为 1
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewIDBuffer 创建 Buffer 并启动后台填充协程，立即开始首次填充
+func NewIDBuffer[T any](mode, bizTag string, nextN func(n int) ([]T, error), size, lowWater int) *IDBuffer[T] {
+	b := &IDBuffer[T]{
+		mode:     mode,
+		bizTag:   bizTag,
+		nextN:    nextN,
+		lowWater: lowWater,
+		ids:      make([]T, size),
+		filled:   make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.refiller()
+	b.signal()
+	return b
+}
+
+// signal 唤醒填充协程，已有待处理的通知时直接返回
+func (b *IDBuffer[T]) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *IDBuffer[T]) refiller() {
+	defer b.wg.Done()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-b.wake:
+		}
+		b.fill()
+	}
+}
+
+// fill 将 Buffer 补满。只有填充协程追加 ID，取号只会让空位变多，因此生成期间无需持锁
+func (b *IDBuffer[T]) fill() {
+	b.mu.Lock()
+	n := len(b.ids) - b.count
+	b.mu.Unlock()
+	if n == 0 {
+		return
+	}
+
+	ids, err := b.nextN(n)
+
+	b.mu.Lock()
+	if err != nil {
+		b.err = err
+	} else {
+		b.err = nil
+		for _, id := range ids {
+			b.ids[(b.head+b.count)%len(b.ids)] = id
+			b.count++
+		}
+	}
+	remaining := b.count
+	// 唤醒所有等待中的请求
+	close(b.filled)
+	b.filled = make(chan struct{})
+	b.mu.Unlock()
+
+	if err != nil {
+		mLog.Error("Failed to fill buffer",
+			zap.String("mode", b.mode),
+			zap.String("biz_tag", b.bizTag),
+			zap.Error(err))
+		return
+	}
+	bufferUsageGauge.WithLabelValues(b.mode, b.bizTag).Set(float64(remaining))
+	mLog.Info("Buffer filled",
+		zap.String("mode", b.mode),
+		zap.String("biz_tag", b.bizTag),
+		zap.Int("count", len(ids)))
+}
+
+// Take 取出最多 n 个 ID。Buffer 为空时等待填充完成，直到 ctx 结束或 Buffer 关闭；填充失败时返回该错误
+func (b *IDBuffer[T]) Take(ctx context.Context, n int) ([]T, error) {
+	b.mu.Lock()
+	for b.count == 0 {
+		b.signal()
+		filled := b.filled
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.done:
+			return nil, errBufferClosed
+		case <-filled:
+		}
+		b.mu.Lock()
+		if b.count == 0 && b.err != nil {
+			err := b.err
+			b.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	n = min(n, b.count)
+	ids := make([]T, n)
+	for i := range ids {
+		ids[i] = b.ids[(b.head+i)%len(b.ids)]
+	}
+	b.head = (b.head + n) % len(b.ids)
+	b.count -= n
+	remaining := b.count
+	if remaining <= b.lowWater {
+		b.signal()
+	}
+	b.mu.Unlock()
+
+	idGenerateCounter.WithLabelValues(b.mode, b.bizTag).Add(float64(n))
+	bufferUsageGauge.WithLabelValues(b.mode, b.bizTag).Set(float64(remaining))
+	return ids, nil
+}
+
+// Len Buffer 中尚未发放的 ID 数量
+func (b *IDBuffer[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// Close 停止填充协程，等待中的请求返回 errBufferClosed。进行中的填充完成后协程才退出，用 Wait 等待
+func (b *IDBuffer[T]) Close() {
+	select {
+	case <-b.done:
+	default:
+		close(b.done)
+	}
+}
+
+// Wait 等待填充协程退出
+func (b *IDBuffer[T]) Wait() {
+	b.wg.Wait()
+}
+
+// labels 返回 Buffer 的模式和业务标识
+func (b *IDBuffer[T]) labels() (mode, bizTag string) {
+	return b.mode, b.bizTag
+}
+
+// idBuffer 不同 ID 类型的 Buffer 共有的操作，用于关闭服务时统一处理
+type idBuffer interface {
+	Len() int
+	Close()
+	Wait()
+	labels() (mode, bizTag string)
+}
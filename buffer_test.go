@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// counter 依次生成 1, 2, 3 ... 的测试生成器，记录每次生成的数量
+type counter struct {
+	mu    sync.Mutex
+	next  int64
+	calls []int
+	err   error
+	block chan struct{} // 非 nil 时每次生成前等待
+}
+
+func (c *counter) NextN(n int) ([]int64, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.calls = append(c.calls, n)
+	ids := make([]int64, n)
+	for i := range ids {
+		c.next++
+		ids[i] = c.next
+	}
+	return ids, nil
+}
+
+func newTestBuffer(t *testing.T, c *counter, size, lowWater int) *IDBuffer[int64] {
+	t.Helper()
+	mLog = zap.NewNop()
+	b := NewIDBuffer("test", "", c.NextN, size, lowWater)
+	t.Cleanup(func() {
+		b.Close()
+		b.Wait()
+	})
+	return b
+}
+
+func TestIDBufferWrapsAroundInOrder(t *testing.T) {
+	b := newTestBuffer(t, &counter{}, 10, 4)
+	var last int64
+	for got := 0; got < 1000; {
+		ids, err := b.Take(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		for _, id := range ids {
+			if id != last+1 {
+				t.Fatalf("id %d after %d, want consecutive", id, last)
+			}
+			last = id
+		}
+		got += len(ids)
+	}
+}
+
+func TestIDBufferConcurrentTakeUnique(t *testing.T) {
+	c := &counter{}
+	b := newTestBuffer(t, c, 64, 16)
+
+	const workers, perWorker = 16, 500
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool, workers*perWorker)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				ids, err := b.Take(context.Background(), 1)
+				if err != nil {
+					t.Errorf("Take: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[ids[0]] {
+					t.Errorf("duplicate id %d", ids[0])
+				}
+				seen[ids[0]] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*perWorker {
+		t.Fatalf("got %d ids, want %d", len(seen), workers*perWorker)
+	}
+	// 只有一个填充协程，每次填充不超过 Buffer 容量
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, n := range c.calls {
+		if n > 64 {
+			t.Fatalf("fill requested %d ids, buffer size is 64", n)
+		}
+	}
+}
+
+func TestIDBufferWaitHonoursContext(t *testing.T) {
+	c := &counter{block: make(chan struct{})}
+	b := newTestBuffer(t, c, 10, 5)
+	defer close(c.block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.Take(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Take error = %v, want DeadlineExceeded", err)
+	}
+
+	// 关闭后等待中的请求立即返回
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		b.Close()
+	}()
+	if _, err := b.Take(context.Background(), 1); !errors.Is(err, errBufferClosed) {
+		t.Fatalf("Take error = %v, want errBufferClosed", err)
+	}
+}
+
+func TestIDBufferReturnsFillError(t *testing.T) {
+	failure := errors.New("backend down")
+	c := &counter{err: failure}
+	b := newTestBuffer(t, c, 10, 5)
+	if _, err := b.Take(context.Background(), 1); !errors.Is(err, failure) {
+		t.Fatalf("Take error = %v, want %v", err, failure)
+	}
+
+	// 后端恢复后请求重新触发填充；恢复前已排队的一次填充仍可能失败，允许重试一次
+	c.mu.Lock()
+	c.err = nil
+	c.mu.Unlock()
+	ids, err := b.Take(context.Background(), 1)
+	if errors.Is(err, failure) {
+		ids, err = b.Take(context.Background(), 1)
+	}
+	if err != nil || ids[0] != 1 {
+		t.Fatalf("Take = %v, %v; want [1]", ids, err)
+	}
+}
+
+// Buffer 一直为空时，nextIDs 在 wait_timeout 后返回 BUFFER_EMPTY，请求被取消或超时时返回对应的状态，均不等待填充完成
+func TestNextIDsReturnsPromptly(t *testing.T) {
+	c := &counter{block: make(chan struct{})}
+	b := newTestBuffer(t, c, 10, 5)
+	t.Cleanup(func() { close(c.block) })
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		waitTimeout time.Duration
+		code        codes.Code
+		reason      string
+	}{
+		{"wait_timeout", func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}, 50 * time.Millisecond, codes.ResourceExhausted, reasonBufferEmpty},
+		{"client cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}, time.Minute, codes.Canceled, ""},
+		{"client deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}, time.Minute, codes.DeadlineExceeded, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			_, err := nextIDs(ctx, b, 1, tt.waitTimeout)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("nextIDs returned after %v", elapsed)
+			}
+			if st := status.Convert(err); st.Code() != tt.code || errorReason(st) != tt.reason {
+				t.Fatalf("nextIDs error = %v, want %v with reason %q", err, tt.code, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package client 在客户端本地租用 ID，减少每个 ID 一次 gRPC 往返。
+//
+// Client 通过 MakeIDBatch 一次租用一批 ID，在本地无锁发放；剩余数量降到低水位时
+// 在后台租用下一批，当前批次用完后直接切换。用法与直接调用 MakeIDService 相同：
+//
+//	c, err := client.New(pb.NewIDMakerClient(conn), client.Options{Mode: "segment", BizTag: "order"})
+//	id, err := c.NextID(ctx)
+//
+// 客户端退出时尚未发放的 ID 会被丢弃，segment 模式下号段因此出现空洞，但不会重复。
+//
+// Dial 连接多个 mid 节点，按轮询分发请求、跳过不健康的节点并在失败时换节点重试，
+// 其 IDMaker 可直接传给 New。
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+)
+
+// ErrClosed Client 已关闭
+var ErrClosed = errors.New("mid client is closed")
+
+// Options 租用参数
+type Options struct {
+	Mode      string        // ID 生成模式："snowflake" 或 "segment"
+	BizTag    string        // 业务标识，仅 segment 模式使用，为空时使用服务端的 "default"
+	BatchSize int           // 每次租用的 ID 数量，不能超过服务端 buffer.max_batch_size，默认 1000
+	LowWater  int           // 本地剩余数量不超过该值时后台租用下一批，默认 BatchSize 的 20%
+	Timeout   time.Duration // 单次 MakeIDBatch 的超时时间，默认 3s
+}
+
+func (o *Options) setDefaults() error {
+	switch o.Mode {
+	case "snowflake", "segment":
+	default:
+		return fmt.Errorf("invalid mode: %q, must be 'snowflake' or 'segment'", o.Mode)
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = 1000
+	}
+	if o.BatchSize < 0 {
+		return errors.New("batch size must be positive")
+	}
+	if o.LowWater == 0 {
+		o.LowWater = o.BatchSize / 5
+	}
+	if o.LowWater < 0 || o.LowWater >= o.BatchSize {
+		return errors.New("low water must be between 0 and batch size - 1")
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 3 * time.Second
+	}
+	return nil
+}
+
+// block 一次租用的 ID，next 为下一个待发放的下标，超过 len(ids) 表示已用完
+type block struct {
+	ids  []int64
+	next atomic.Int64
+}
+
+// Client 本地租用 ID 的客户端，可被多个协程并发使用
+type Client struct {
+	rpc  pb.IDMakerClient
+	opts Options
+
+	current atomic.Pointer[block]
+
+	mu       sync.Mutex
+	spare    *block        // 已租用、尚未启用的下一批
+	fetching bool          // 同一时间最多一次租用
+	fetched  chan struct{} // 每次租用结束时关闭，唤醒等待中的请求
+	err      error         // 最近一次租用的错误，由下一个等待的请求取走
+	closed   bool
+	fetches  sync.WaitGroup
+
+	stats stats
+}
+
+// New 创建 Client，首次调用 NextID 时才开始租用
+func New(rpc pb.IDMakerClient, opts Options) (*Client, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	c := &Client{
+		rpc:     rpc,
+		opts:    opts,
+		fetched: make(chan struct{}),
+	}
+	c.current.Store(&block{})
+	return c, nil
+}
+
+// NextID 返回一个 ID。本地有剩余时不加锁也不访问网络；
+// 本地用完且下一批尚未租到时等待租用完成，直到 ctx 结束
+func (c *Client) NextID(ctx context.Context) (int64, error) {
+	waited := false
+	for {
+		b := c.current.Load()
+		i := b.next.Add(1) - 1
+		if i < int64(len(b.ids)) {
+			// 恰好越过低水位的请求负责触发后台租用
+			if int64(len(b.ids))-i-1 == int64(c.opts.LowWater) {
+				c.startFetch()
+			}
+			if waited {
+				c.stats.misses.Add(1)
+			} else {
+				c.stats.hits.Add(1)
+			}
+			return b.ids[i], nil
+		}
+		w, err := c.switchBlock(ctx, b)
+		if err != nil {
+			return 0, err
+		}
+		waited = waited || w
+	}
+}
+
+// switchBlock 在 used 用完后启用下一批，下一批尚未租到时等待，返回是否等待过租用
+func (c *Client) switchBlock(ctx context.Context, used *block) (bool, error) {
+	waited := false
+	c.mu.Lock()
+	for {
+		if c.closed {
+			c.mu.Unlock()
+			return waited, ErrClosed
+		}
+		if c.current.Load() != used {
+			// 其他请求已经切换
+			break
+		}
+		if c.spare != nil {
+			c.current.Store(c.spare)
+			c.spare = nil
+			break
+		}
+		if c.err != nil && !c.fetching {
+			err := c.err
+			c.err = nil
+			if waited {
+				c.mu.Unlock()
+				return waited, err
+			}
+			// 错误来自之前的后台租用，重新租用一次
+		}
+		c.startFetchLocked()
+		fetched := c.fetched
+		c.mu.Unlock()
+		waited = true
+		select {
+		case <-ctx.Done():
+			return waited, ctx.Err()
+		case <-fetched:
+		}
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+	return waited, nil
+}
+
+func (c *Client) startFetch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startFetchLocked()
+}
+
+// startFetchLocked 在没有进行中的租用且没有备用批次时开始后台租用，调用方需持有 mu
+func (c *Client) startFetchLocked() {
+	if c.closed || c.fetching || c.spare != nil {
+		return
+	}
+	c.fetching = true
+	c.fetches.Add(1)
+	go func() {
+		defer c.fetches.Done()
+		ids, err := c.fetch()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.fetching = false
+		if err != nil {
+			c.err = err
+		} else {
+			c.err = nil
+			c.spare = &block{ids: ids}
+		}
+		close(c.fetched)
+		c.fetched = make(chan struct{})
+	}()
+}
+
+func (c *Client) fetch() ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.rpc.MakeIDBatch(ctx, &pb.MakeIDBatchRequest{
+		Mode:   c.opts.Mode,
+		BizTag: c.opts.BizTag,
+		Count:  int32(c.opts.BatchSize),
+	})
+	c.stats.observeFetch(time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Ids) == 0 {
+		return nil, errors.New("server returned an empty batch")
+	}
+	c.stats.leased.Add(int64(len(resp.Ids)))
+	return resp.Ids, nil
+}
+
+// Close 停止租用并等待进行中的租用结束，之后 NextID 返回 ErrClosed。本地剩余的 ID 被丢弃
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.current.Store(&block{})
+	c.mu.Unlock()
+	c.fetches.Wait()
+	return nil
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServer 按请求数量依次分配 ID 的 IDMakerClient
+type fakeServer struct {
+	pb.IDMakerClient
+
+	mu      sync.Mutex
+	next    int64
+	calls   int
+	latency time.Duration
+	err     error
+}
+
+func (f *fakeServer) MakeIDBatch(ctx context.Context, req *pb.MakeIDBatchRequest, opts ...grpc.CallOption) (*pb.MakeIDBatchResponse, error) {
+	if f.latency > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.latency):
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	ids := make([]int64, req.Count)
+	for i := range ids {
+		f.next++
+		ids[i] = f.next
+	}
+	return &pb.MakeIDBatchResponse{Ids: ids}, nil
+}
+
+func (f *fakeServer) setErr(err error) {
+	f.mu.Lock()
+	f.err = err
+	f.mu.Unlock()
+}
+
+func newTestClient(t *testing.T, f *fakeServer, opts Options) *Client {
+	t.Helper()
+	c, err := New(f, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Mode: "uuid"},
+		{Mode: "segment", BatchSize: -1},
+		{Mode: "segment", BatchSize: 10, LowWater: 10},
+	} {
+		if _, err := New(&fakeServer{}, opts); err == nil {
+			t.Errorf("New(%+v) succeeded", opts)
+		}
+	}
+}
+
+func TestClientRefillsBeforeExhaustion(t *testing.T) {
+	f := &fakeServer{latency: 2 * time.Millisecond}
+	c := newTestClient(t, f, Options{Mode: "segment", BatchSize: 100, LowWater: 50})
+
+	// 逐个取号，留出后台租用的时间：只有第一次需要等待
+	for want := int64(1); want <= 1000; want++ {
+		id, err := c.NextID(context.Background())
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		if id != want {
+			t.Fatalf("NextID = %d, want %d", id, want)
+		}
+		if want%5 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	stats := c.Stats()
+	if stats.Misses != 1 || stats.Hits != 999 {
+		t.Fatalf("hits = %d, misses = %d; want 999, 1", stats.Hits, stats.Misses)
+	}
+	if stats.HitRate() != 0.999 {
+		t.Fatalf("HitRate = %v, want 0.999", stats.HitRate())
+	}
+	if stats.Fetches > 11 {
+		t.Fatalf("fetches = %d, want at most 11", stats.Fetches)
+	}
+}
+
+func TestClientConcurrentUnique(t *testing.T) {
+	f := &fakeServer{latency: time.Millisecond}
+	c := newTestClient(t, f, Options{Mode: "snowflake", BatchSize: 64})
+
+	const workers, perWorker = 16, 500
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool, workers*perWorker)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				id, err := c.NextID(context.Background())
+				if err != nil {
+					t.Errorf("NextID: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*perWorker {
+		t.Fatalf("got %d ids, want %d", len(seen), workers*perWorker)
+	}
+	if s := c.Stats(); s.Hits+s.Misses != workers*perWorker {
+		t.Fatalf("hits + misses = %d, want %d", s.Hits+s.Misses, workers*perWorker)
+	}
+}
+
+func TestClientReturnsLeaseError(t *testing.T) {
+	f := &fakeServer{err: status.Error(codes.Unavailable, "down")}
+	c := newTestClient(t, f, Options{Mode: "segment", BatchSize: 10})
+	if _, err := c.NextID(context.Background()); status.Code(err) != codes.Unavailable {
+		t.Fatalf("NextID error = %v, want Unavailable", err)
+	}
+
+	// 服务恢复后下一次调用重新租用
+	f.setErr(nil)
+	if id, err := c.NextID(context.Background()); err != nil || id != 1 {
+		t.Fatalf("NextID = %d, %v; want 1", id, err)
+	}
+	if s := c.Stats(); s.FetchErrors != 1 {
+		t.Fatalf("fetch errors = %d, want 1", s.FetchErrors)
+	}
+}
+
+func TestClientHonoursContextAndClose(t *testing.T) {
+	f := &fakeServer{latency: time.Hour}
+	c, err := New(f, Options{Mode: "segment", Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.NextID(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NextID error = %v, want DeadlineExceeded", err)
+	}
+
+	// Close 等待进行中的租用超时结束
+	c.Close()
+	if _, err := c.NextID(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("NextID after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func BenchmarkClientNextID(b *testing.B) {
+	c, err := New(&fakeServer{}, Options{Mode: "segment", BatchSize: 10000})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := c.NextID(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // 启用客户端健康检查
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+// DialOptions 多节点连接参数
+type DialOptions struct {
+	HealthService  string            // 健康检查的服务名，为空时检查整个节点；只用 segment 模式时可设为 "segment"
+	Attempts       int               // 每个请求最多尝试的次数，失败后换下一个节点重试，默认 3
+	AttemptTimeout time.Duration     // 单次尝试的超时时间，默认 1s，不会超过调用方 ctx 的截止时间
+	Backoff        time.Duration     // 两次尝试之间的等待时间，默认 50ms
+	GRPCOptions    []grpc.DialOption // 额外的 gRPC 选项，默认使用不加密的连接
+}
+
+func (o *DialOptions) setDefaults() {
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.AttemptTimeout <= 0 {
+		o.AttemptTimeout = time.Second
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 50 * time.Millisecond
+	}
+}
+
+// Conn 到多个 mid 节点的连接，按轮询选择节点，跳过健康检查不通过的节点，
+// 请求失败时在截止时间内换下一个节点重试。可被多个协程并发使用
+type Conn struct {
+	cc   *grpc.ClientConn
+	rpc  pb.IDMakerClient
+	opts DialOptions
+}
+
+// Dial 连接 endpoints 中的 mid 节点。endpoints 为静态的 "host:port" 列表，
+// 或者一个 "dns:///host:port" 目标，由 DNS 解析出所有节点的地址
+func Dial(endpoints []string, opts DialOptions) (*Conn, error) {
+	opts.setDefaults()
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+
+	serviceConfig := fmt.Sprintf(`{
+		"loadBalancingConfig": [{"round_robin": {}}],
+		"healthCheckConfig": {"serviceName": %q}
+	}`, opts.HealthService)
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}
+
+	var target string
+	if len(endpoints) == 1 && strings.HasPrefix(endpoints[0], "dns:///") {
+		target = endpoints[0]
+	} else {
+		addrs := make([]resolver.Address, len(endpoints))
+		for i, endpoint := range endpoints {
+			if strings.Contains(endpoint, "://") {
+				return nil, fmt.Errorf("invalid endpoint %q: a dns target must be the only endpoint", endpoint)
+			}
+			addrs[i] = resolver.Address{Addr: endpoint}
+		}
+		r := manual.NewBuilderWithScheme("mid")
+		r.InitialState(resolver.State{Addresses: addrs})
+		dialOpts = append(dialOpts, grpc.WithResolvers(r))
+		target = r.Scheme() + ":///static"
+	}
+
+	cc, err := grpc.NewClient(target, append(dialOpts, opts.GRPCOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{cc: cc, rpc: pb.NewIDMakerClient(cc), opts: opts}, nil
+}
+
+// IDMaker 返回不带重试的 IDMaker 客户端，请求同样按轮询分发到健康的节点。
+// 可传给 New 在本地租用 ID
+func (c *Conn) IDMaker() pb.IDMakerClient {
+	return c.rpc
+}
+
+// ID 一个 ID 及其来源节点
+type ID struct {
+	Value int64
+	Node  string // 生成该 ID 的节点地址，用于排查问题
+}
+
+// NextID 生成一个 ID
+func (c *Conn) NextID(ctx context.Context, mode, bizTag string) (ID, error) {
+	var id ID
+	err := c.retry(ctx, func(ctx context.Context, p *peer.Peer) error {
+		resp, err := c.rpc.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: mode, BizTag: bizTag}, grpc.Peer(p))
+		if err != nil {
+			return err
+		}
+		id = ID{Value: resp.Id, Node: addrOf(p)}
+		return nil
+	})
+	return id, err
+}
+
+// Batch 一批 ID 及其来源节点
+type Batch struct {
+	IDs  []int64
+	Node string
+}
+
+// NextIDs 一次生成 count 个 ID，count 不能超过服务端 buffer.max_batch_size
+func (c *Conn) NextIDs(ctx context.Context, mode, bizTag string, count int) (Batch, error) {
+	var batch Batch
+	err := c.retry(ctx, func(ctx context.Context, p *peer.Peer) error {
+		resp, err := c.rpc.MakeIDBatch(ctx, &pb.MakeIDBatchRequest{Mode: mode, BizTag: bizTag, Count: int32(count)}, grpc.Peer(p))
+		if err != nil {
+			return err
+		}
+		batch = Batch{IDs: resp.Ids, Node: addrOf(p)}
+		return nil
+	})
+	return batch, err
+}
+
+// retry 最多尝试 Attempts 次，每次不超过 AttemptTimeout。生成 ID 是幂等的：
+// 失败的尝试即使已在服务端发号，重试也只会浪费 ID 而不会重复
+func (c *Conn) retry(ctx context.Context, call func(ctx context.Context, p *peer.Peer) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.opts.AttemptTimeout)
+		p := &peer.Peer{}
+		err = call(attemptCtx, p)
+		cancel()
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+		if attempt >= c.opts.Attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.opts.Backoff):
+		}
+	}
+	return err
+}
+
+// retryable 节点不可用、单次尝试超时或节点过载时换节点重试，参数错误等不重试
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+func addrOf(p *peer.Peer) string {
+	if p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+// Close 关闭到所有节点的连接
+func (c *Conn) Close() error {
+	return c.cc.Close()
+}
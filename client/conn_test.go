@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// testNode 进程内的 mid 节点，ID 的高位为节点编号
+type testNode struct {
+	pb.UnimplementedIDMakerServer
+	index  int64
+	next   atomic.Int64
+	fail   atomic.Bool // 为 true 时返回 Unavailable
+	addr   string
+	server *grpc.Server
+	health *health.Server
+}
+
+func (n *testNode) MakeIDService(ctx context.Context, req *pb.MakeIDServiceRequest) (*pb.MakeIDServiceResponse, error) {
+	if n.fail.Load() {
+		return nil, status.Error(codes.Unavailable, "injected failure")
+	}
+	if req.Mode != "snowflake" && req.Mode != "segment" {
+		return nil, status.Error(codes.InvalidArgument, "invalid mode")
+	}
+	return &pb.MakeIDServiceResponse{Id: n.index<<32 | n.next.Add(1)}, nil
+}
+
+func (n *testNode) MakeIDBatch(ctx context.Context, req *pb.MakeIDBatchRequest) (*pb.MakeIDBatchResponse, error) {
+	if n.fail.Load() {
+		return nil, status.Error(codes.Unavailable, "injected failure")
+	}
+	ids := make([]int64, req.Count)
+	for i := range ids {
+		ids[i] = n.index<<32 | n.next.Add(1)
+	}
+	return &pb.MakeIDBatchResponse{Ids: ids}, nil
+}
+
+func startTestNodes(t *testing.T, count int) []*testNode {
+	t.Helper()
+	nodes := make([]*testNode, count)
+	for i := range nodes {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := &testNode{index: int64(i + 1), addr: l.Addr().String(), server: grpc.NewServer(), health: health.NewServer()}
+		pb.RegisterIDMakerServer(n.server, n)
+		healthpb.RegisterHealthServer(n.server, n.health)
+		go n.server.Serve(l)
+		t.Cleanup(n.server.Stop)
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func addrs(nodes []*testNode) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.addr)
+	}
+	return out
+}
+
+func dialTestNodes(t *testing.T, nodes []*testNode, opts DialOptions) *Conn {
+	t.Helper()
+	c, err := Dial(addrs(nodes), opts)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// servedBy 发送 count 个请求，统计每个节点处理的数量
+func servedBy(t *testing.T, c *Conn, count int) map[string]int {
+	t.Helper()
+	served := make(map[string]int)
+	for i := 0; i < count; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		id, err := c.NextID(ctx, "segment", "")
+		cancel()
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		served[id.Node]++
+	}
+	return served
+}
+
+// waitFor 等待健康状态变化传播到客户端
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestConnRoundRobinSkipsUnhealthyNodes(t *testing.T) {
+	nodes := startTestNodes(t, 3)
+	c := dialTestNodes(t, nodes, DialOptions{})
+
+	// 连接建立后请求均匀分布到三个节点
+	waitFor(t, func() bool { return len(servedBy(t, c, 3)) == 3 })
+	served := servedBy(t, c, 30)
+	for _, n := range nodes {
+		if served[n.addr] != 10 {
+			t.Fatalf("served = %v, want 10 requests per node", served)
+		}
+	}
+
+	// 健康检查不通过的节点不再接收请求
+	nodes[1].health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	waitFor(t, func() bool { return servedBy(t, c, 10)[nodes[1].addr] == 0 })
+	served = servedBy(t, c, 20)
+	if served[nodes[0].addr] != 10 || served[nodes[2].addr] != 10 {
+		t.Fatalf("served = %v, want 10 requests each on nodes 0 and 2", served)
+	}
+
+	// 恢复后重新加入轮询
+	nodes[1].health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	waitFor(t, func() bool { return servedBy(t, c, 3)[nodes[1].addr] > 0 })
+}
+
+func TestConnRetriesOnAnotherNode(t *testing.T) {
+	nodes := startTestNodes(t, 2)
+	c := dialTestNodes(t, nodes, DialOptions{Backoff: time.Millisecond})
+	waitFor(t, func() bool { return len(servedBy(t, c, 2)) == 2 })
+
+	// 节点 0 健康检查正常但请求失败，每个请求都由节点 1 完成
+	nodes[0].fail.Store(true)
+	for i := 0; i < 10; i++ {
+		batch, err := c.NextIDs(context.Background(), "segment", "", 5)
+		if err != nil {
+			t.Fatalf("NextIDs: %v", err)
+		}
+		if batch.Node != nodes[1].addr || len(batch.IDs) != 5 || batch.IDs[0]>>32 != 2 {
+			t.Fatalf("batch = %+v, want 5 ids from %s", batch, nodes[1].addr)
+		}
+	}
+
+	// 参数错误不重试
+	if _, err := c.NextID(context.Background(), "uuid", ""); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("NextID(uuid) error = %v, want InvalidArgument", err)
+	}
+
+	// 所有节点都失败时在尝试次数用完后返回最后一个错误
+	nodes[1].fail.Store(true)
+	if _, err := c.NextID(context.Background(), "segment", ""); status.Code(err) != codes.Unavailable {
+		t.Fatalf("NextID error = %v, want Unavailable", err)
+	}
+}
+
+func TestConnSurvivesNodeShutdown(t *testing.T) {
+	nodes := startTestNodes(t, 3)
+	c := dialTestNodes(t, nodes, DialOptions{})
+	waitFor(t, func() bool { return len(servedBy(t, c, 3)) == 3 })
+
+	nodes[0].server.Stop()
+	served := servedBy(t, c, 20)
+	if served[nodes[0].addr] != 0 || served[nodes[1].addr]+served[nodes[2].addr] != 20 {
+		t.Fatalf("served = %v after stopping %s", served, nodes[0].addr)
+	}
+}
+
+func TestConnWithLeasingClient(t *testing.T) {
+	nodes := startTestNodes(t, 2)
+	conn := dialTestNodes(t, nodes, DialOptions{})
+	c, err := New(conn.IDMaker(), Options{Mode: "segment", BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		id, err := c.NextID(context.Background())
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestDialRejectsMixedTargets(t *testing.T) {
+	if _, err := Dial(nil, DialOptions{}); err == nil {
+		t.Fatal("Dial with no endpoints succeeded")
+	}
+	if _, err := Dial([]string{"127.0.0.1:1", "dns:///mid:50051"}, DialOptions{}); err == nil {
+		t.Fatal("Dial with a dns target among static endpoints succeeded")
+	}
+}
+
+func TestDialDNSTarget(t *testing.T) {
+	nodes := startTestNodes(t, 1)
+	_, port, _ := net.SplitHostPort(nodes[0].addr)
+	c, err := Dial([]string{"dns:///localhost:" + port}, DialOptions{})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	id, err := c.NextID(context.Background(), "snowflake", "")
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	if id.Node != nodes[0].addr {
+		t.Fatalf("served by %q, want %q", id.Node, nodes[0].addr)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stats 客户端本地租用的统计
+type Stats struct {
+	Hits          int64         // 无需等待、直接从本地发放的次数
+	Misses        int64         // 本地用完后等待租用的次数
+	Leased        int64         // 累计租用的 ID 数量
+	Fetches       int64         // MakeIDBatch 调用次数
+	FetchErrors   int64         // MakeIDBatch 失败次数
+	FetchDuration time.Duration // MakeIDBatch 累计耗时
+}
+
+// HitRate 本地命中率，没有请求时为 0
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type stats struct {
+	hits, misses, leased, fetches, fetchErrors, fetchNanos atomic.Int64
+}
+
+func (s *stats) observeFetch(d time.Duration, err error) {
+	s.fetches.Add(1)
+	s.fetchNanos.Add(int64(d))
+	if err != nil {
+		s.fetchErrors.Add(1)
+	}
+}
+
+// Stats 返回当前统计
+func (c *Client) Stats() Stats {
+	return Stats{
+		Hits:          c.stats.hits.Load(),
+		Misses:        c.stats.misses.Load(),
+		Leased:        c.stats.leased.Load(),
+		Fetches:       c.stats.fetches.Load(),
+		FetchErrors:   c.stats.fetchErrors.Load(),
+		FetchDuration: time.Duration(c.stats.fetchNanos.Load()),
+	}
+}
+
+var (
+	requestsDesc = prometheus.NewDesc("mid_client_requests_total",
+		"Number of NextID calls, by whether they were served locally (hit) or had to wait for a lease (miss)",
+		[]string{"mode", "biz_tag", "result"}, nil)
+	leasedDesc = prometheus.NewDesc("mid_client_leased_ids_total",
+		"Number of IDs leased from the server",
+		[]string{"mode", "biz_tag"}, nil)
+	fetchesDesc = prometheus.NewDesc("mid_client_lease_requests_total",
+		"Number of MakeIDBatch calls, by result",
+		[]string{"mode", "biz_tag", "result"}, nil)
+	fetchSecondsDesc = prometheus.NewDesc("mid_client_lease_seconds_total",
+		"Total time spent in MakeIDBatch calls",
+		[]string{"mode", "biz_tag"}, nil)
+)
+
+// Collector 返回导出本客户端统计的 Prometheus Collector，由调用方注册
+func (c *Client) Collector() prometheus.Collector {
+	return collector{c}
+}
+
+type collector struct {
+	c *Client
+}
+
+func (x collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- requestsDesc
+	ch <- leasedDesc
+	ch <- fetchesDesc
+	ch <- fetchSecondsDesc
+}
+
+func (x collector) Collect(ch chan<- prometheus.Metric) {
+	s := x.c.Stats()
+	mode, bizTag := x.c.opts.Mode, x.c.opts.BizTag
+	ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(s.Hits), mode, bizTag, "hit")
+	ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(s.Misses), mode, bizTag, "miss")
+	ch <- prometheus.MustNewConstMetric(leasedDesc, prometheus.CounterValue, float64(s.Leased), mode, bizTag)
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(s.Fetches-s.FetchErrors), mode, bizTag, "ok")
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(s.FetchErrors), mode, bizTag, "error")
+	ch <- prometheus.MustNewConstMetric(fetchSecondsDesc, prometheus.CounterValue, s.FetchDuration.Seconds(), mode, bizTag)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile 在临时目录中写入配置文件，返回路径
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "mid.yaml", `
+grpc:
+  addr: ":6000"
+buffer:
+  size: 6000
+snowflake:
+  machine_id: 5
+`)
+	tests := []struct {
+		name        string
+		file        bool
+		env         map[string]string
+		args        []string
+		addr        string
+		bufferSize  int
+		machineID   int64
+		waitTimeout time.Duration
+	}{
+		{"defaults", false, nil, nil, ":50051", 10000, 1, 3 * time.Second},
+		{"file over defaults", true, nil, nil, ":6000", 6000, 5, 3 * time.Second},
+		{"env over file", true, map[string]string{"MID_GRPC_ADDR": ":7000", "MID_BUFFER_SIZE": "7000"}, nil, ":7000", 7000, 5, 3 * time.Second},
+		{"flag over env", true, map[string]string{"MID_GRPC_ADDR": ":7000", "MID_BUFFER_SIZE": "7000"},
+			[]string{"-grpc.addr", ":8000", "-buffer.wait_timeout", "1s"}, ":8000", 7000, 5, time.Second},
+		{"config path from env", false, map[string]string{"MID_CONFIG": file}, nil, ":6000", 6000, 5, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file {
+				args = append([]string{"-config", file}, args...)
+			}
+			cfg, err := LoadConfig(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.GRPC.Addr != tt.addr || cfg.Buffer.Size != tt.bufferSize || cfg.Snowflake.MachineID != tt.machineID || cfg.Buffer.WaitTimeout != tt.waitTimeout {
+				t.Fatalf("got addr %q, buffer.size %d, machine_id %d, wait_timeout %v; want %q, %d, %d, %v",
+					cfg.GRPC.Addr, cfg.Buffer.Size, cfg.Snowflake.MachineID, cfg.Buffer.WaitTimeout,
+					tt.addr, tt.bufferSize, tt.machineID, tt.waitTimeout)
+			}
+			// 未覆盖的字段保持默认值
+			if cfg.Metrics.Addr != ":9190" {
+				t.Fatalf("metrics.addr = %q, want default", cfg.Metrics.Addr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigYAMLAndTOML(t *testing.T) {
+	yamlPath := writeConfigFile(t, "mid.yml", `
+grpc:
+  addr: ":6000"
+  keepalive:
+    time: 10s
+segment:
+  backend: memory
+  memory:
+    step: 500
+snowflake:
+  layout:
+    time_unit: 10ms
+    sequence_bits: 8
+`)
+	tomlPath := writeConfigFile(t, "mid.toml", `
+[grpc]
+addr = ":6000"
+[grpc.keepalive]
+time = "10s"
+[segment]
+backend = "memory"
+[segment.memory]
+step = 500
+[snowflake.layout]
+time_unit = "10ms"
+sequence_bits = 8
+`)
+	fromYAML, err := LoadConfig([]string{"-config", yamlPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTOML, err := LoadConfig([]string{"-config", tomlPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromTOML) {
+		t.Fatalf("yaml and toml configs differ:\n%s\n%s", fromYAML, fromTOML)
+	}
+	if fromYAML.GRPC.Keepalive.Time != 10*time.Second || fromYAML.Snowflake.Layout.TimeUnit != 10*time.Millisecond || fromYAML.Segment.Memory.Step != 500 {
+		t.Fatalf("config not loaded from file:\n%s", fromYAML)
+	}
+
+	if _, err := LoadConfig([]string{"-config", writeConfigFile(t, "mid.json", "{}")}); err == nil {
+		t.Fatal("loaded a config file with an unsupported extension")
+	}
+	if _, err := LoadConfig([]string{"-config", writeConfigFile(t, "bad.yaml", "grpc: [")}); err == nil {
+		t.Fatal("loaded an invalid yaml file")
+	}
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	if _, err := LoadConfig([]string{"-buffer.size", "many"}); err == nil {
+		t.Fatal("accepted a non-integer flag")
+	}
+	t.Setenv("MID_BUFFER_WAIT_TIMEOUT", "soon")
+	if _, err := LoadConfig(nil); err == nil || !strings.Contains(err.Error(), "MID_BUFFER_WAIT_TIMEOUT") {
+		t.Fatalf("error = %v, want one naming MID_BUFFER_WAIT_TIMEOUT", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // 为空表示校验通过
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"empty grpc addr", func(c *Config) { c.GRPC.Addr = "" }, "grpc.addr"},
+		{"gateway on metrics addr", func(c *Config) { c.Gateway.Addr = c.Metrics.Addr }, "gateway.addr"},
+		{"redis on grpc addr", func(c *Config) { c.Redis.Addr = c.GRPC.Addr }, "redis.addr"},
+		{"machine id above layout", func(c *Config) { c.Snowflake.MachineID = 32 }, "snowflake.machine_id must be between 0 and 31"},
+		{"machine id ignored with registry", func(c *Config) {
+			c.Snowflake.MachineID = 32
+			c.Snowflake.WorkerRegistry = true
+			c.MySQL.DSN = "root@tcp(127.0.0.1:3306)/mid"
+		}, ""},
+		{"registry without mysql", func(c *Config) { c.Snowflake.WorkerRegistry = true }, "requires mysql.dsn"},
+		{"negative datacenter id", func(c *Config) { c.Snowflake.DatacenterID = -1 }, "snowflake.datacenter_id"},
+		{"short lease", func(c *Config) { c.Snowflake.LeaseTTL = time.Second }, "snowflake.lease_ttl"},
+		{"invalid layout", func(c *Config) { c.Snowflake.Layout.SequenceBits = 30 }, "snowflake.layout"},
+		{"unknown backend", func(c *Config) { c.Segment.Backend = "redis" }, "segment.backend"},
+		{"postgres without dsn", func(c *Config) { c.Segment.Backend = backendPostgres }, "segment.postgres.dsn"},
+		{"raft without internal addr", func(c *Config) {
+			c.Segment.Backend = backendRaft
+			c.Segment.Raft.NodeID = "n1"
+			c.Segment.Raft.Peers = "n1=127.0.0.1:7001"
+			c.Segment.Raft.ForwardAddrs = "n1=127.0.0.1:7101"
+		}, "segment.raft.internal_addr"},
+		{"memory failure rate", func(c *Config) {
+			c.Segment.Backend = backendMemory
+			c.Segment.Memory.FailureRate = 1.5
+		}, "segment.memory.failure_rate"},
+		{"preload percent", func(c *Config) { c.Segment.PreloadPercent = 0 }, "segment.preload_percent"},
+		{"low water not below size", func(c *Config) { c.Buffer.LowWater = c.Buffer.Size }, "buffer.low_water"},
+		{"log level", func(c *Config) { c.Log.Level = "trace" }, "log.level"},
+		{"shutdown timeout", func(c *Config) { c.ShutdownTimeout = 0 }, "shutdown_timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"", ""},
+		{"root:secret@tcp(127.0.0.1:3306)/mid?parseTime=true", "root:xxxxx@tcp(127.0.0.1:3306)/mid?parseTime=true"},
+		{"root@tcp(127.0.0.1:3306)/mid", "root@tcp(127.0.0.1:3306)/mid"},
+		{"postgres://mid:secret@db:5432/mid?sslmode=disable", "postgres://mid:xxxxx@db:5432/mid?sslmode=disable"},
+		{"host=db user=mid password=secret dbname=mid", "xxxxx"},
+	}
+	for _, tt := range tests {
+		if got := redactDSN(tt.dsn); got != tt.want {
+			t.Errorf("redactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+
+	cfg := defaultConfig()
+	cfg.MySQL.DSN = "root:secret@tcp(127.0.0.1:3306)/mid"
+	cfg.Segment.Postgres.DSN = "postgres://mid:secret@db:5432/mid"
+	if s := cfg.String(); strings.Contains(s, "secret") {
+		t.Fatalf("String() leaks the password:\n%s", s)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain ErrorInfo 的 domain
+const errorDomain = "mid"
+
+// ErrorInfo 的 reason，调用方据此区分错误，无需解析错误消息。
+// 可重试与不可重试的划分见 proto/id_maker.proto 中 IDMaker 服务的注释
+const (
+	reasonBizTagNotFound     = "BIZ_TAG_NOT_FOUND"   // NOT_FOUND
+	reasonSegmentDisabled    = "SEGMENT_DISABLED"    // FAILED_PRECONDITION，节点未配置号段存储后端
+	reasonInvalidStep        = "INVALID_STEP"        // FAILED_PRECONDITION，业务标识的步长配置错误
+	reasonTimestampOverflow  = "TIMESTAMP_OVERFLOW"  // FAILED_PRECONDITION，时间戳位数用尽
+	reasonClockRollback      = "CLOCK_ROLLBACK"      // UNAVAILABLE
+	reasonLeaseLost          = "LEASE_LOST"          // UNAVAILABLE
+	reasonBackendUnavailable = "BACKEND_UNAVAILABLE" // UNAVAILABLE，号段存储后端出错
+	reasonShuttingDown       = "SHUTTING_DOWN"       // UNAVAILABLE
+	reasonBufferEmpty        = "BUFFER_EMPTY"        // RESOURCE_EXHAUSTED，等待 Buffer 填充超时
+	reasonInternal           = "INTERNAL"            // INTERNAL
+)
+
+// 通过 RetryInfo 建议的重试间隔
+const (
+	bufferRetryDelay  = 100 * time.Millisecond // Buffer 为空时，填充通常很快完成
+	backendRetryDelay = time.Second            // 存储后端不可用或时钟回退时，恢复需要更长时间
+)
+
+// generateError 将生成 ID 时的错误转换为带 ErrorInfo（以及可重试时的 RetryInfo）的 gRPC 状态
+func generateError(err error, mode, bizTag string) error {
+	metadata := map[string]string{"mode": mode}
+	if bizTag != "" {
+		metadata["biz_tag"] = bizTag
+	}
+	switch {
+	case errors.Is(err, errBizTagNotFound):
+		return errorWithInfo(codes.NotFound, fmt.Sprintf("biz_tag %q not found", bizTag), reasonBizTagNotFound, metadata, 0)
+	case errors.Is(err, errInvalidStep):
+		return errorWithInfo(codes.FailedPrecondition, err.Error(), reasonInvalidStep, metadata, 0)
+	case errors.Is(err, errTimestampOverflow):
+		return errorWithInfo(codes.FailedPrecondition, err.Error(), reasonTimestampOverflow, metadata, 0)
+	case errors.Is(err, errClockRollback):
+		return errorWithInfo(codes.Unavailable, err.Error(), reasonClockRollback, metadata, backendRetryDelay)
+	case errors.Is(err, errLeaseLost):
+		return errorWithInfo(codes.Unavailable, err.Error(), reasonLeaseLost, metadata, backendRetryDelay)
+	case errors.Is(err, errBufferClosed), errors.Is(err, errSegmentClosed):
+		// 不建议等待，调用方应立即换其他节点重试
+		return errorWithInfo(codes.Unavailable, "server is shutting down", reasonShuttingDown, metadata, 0)
+	case mode == "segment":
+		// 其余号段错误都来自存储后端，例如 MySQL 不可达、Raft 集群没有 leader
+		return errorWithInfo(codes.Unavailable, fmt.Sprintf("segment backend unavailable: %v", err), reasonBackendUnavailable, metadata, backendRetryDelay)
+	}
+	return errorWithInfo(codes.Internal, err.Error(), reasonInternal, metadata, 0)
+}
+
+// bufferEmptyError 等待 Buffer 填充超时
+func bufferEmptyError(mode, bizTag string) error {
+	metadata := map[string]string{"mode": mode}
+	if bizTag != "" {
+		metadata["biz_tag"] = bizTag
+	}
+	return errorWithInfo(codes.ResourceExhausted, "timed out waiting for buffer to be filled", reasonBufferEmpty, metadata, bufferRetryDelay)
+}
+
+// segmentDisabledError 节点未配置号段存储后端
+func segmentDisabledError() error {
+	return errorWithInfo(codes.FailedPrecondition, "segment mode is not enabled on this node", reasonSegmentDisabled, map[string]string{"mode": "segment"}, 0)
+}
+
+// errorWithInfo 创建附带 ErrorInfo 的状态，delay 大于 0 时附带 RetryInfo
+func errorWithInfo(c codes.Code, msg, reason string, metadata map[string]string, delay time.Duration) error {
+	st := status.New(c, msg)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata}); err == nil {
+		st = withInfo
+	}
+	if delay > 0 {
+		if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}); err == nil {
+			st = withRetry
+		}
+	}
+	return st.Err()
+}
+
+// invalidArgument 请求参数错误，附带指出字段的 BadRequest
+func invalidArgument(field, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	st := status.New(codes.InvalidArgument, msg)
+	if withViolation, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: msg}},
+	}); err == nil {
+		st = withViolation
+	}
+	return st.Err()
+}
+
+// errorReason 返回状态中 ErrorInfo 的 reason，没有时返回空字符串
+func errorReason(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// retryDelay 返回状态中 RetryInfo 建议的重试间隔，没有时返回 0
+func retryDelay(st *status.Status) time.Duration {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGenerateError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		mode   string
+		code   codes.Code
+		reason string
+		retry  time.Duration
+	}{
+		{"biz_tag not found", errBizTagNotFound, "segment", codes.NotFound, reasonBizTagNotFound, 0},
+		{"invalid step", fmt.Errorf("%w 0 for biz_tag order", errInvalidStep), "segment", codes.FailedPrecondition, reasonInvalidStep, 0},
+		{"timestamp overflow", fmt.Errorf("%w: 41 timestamp bits", errTimestampOverflow), "snowflake", codes.FailedPrecondition, reasonTimestampOverflow, 0},
+		{"clock rollback", errClockRollback, "snowflake", codes.Unavailable, reasonClockRollback, backendRetryDelay},
+		{"lease lost", errLeaseLost, "snowflake", codes.Unavailable, reasonLeaseLost, backendRetryDelay},
+		{"shutting down", errBufferClosed, "segment", codes.Unavailable, reasonShuttingDown, 0},
+		{"backend error", errors.New("dial tcp: connection refused"), "segment", codes.Unavailable, reasonBackendUnavailable, backendRetryDelay},
+		{"no raft leader", errNoRaftLeader, "segment", codes.Unavailable, reasonBackendUnavailable, backendRetryDelay},
+		{"forwarded leader error", forwardError("n1", status.Error(codes.Unavailable, "node n1 is not the raft leader")), "segment", codes.Unavailable, reasonBackendUnavailable, backendRetryDelay},
+		{"unexpected snowflake error", errors.New("boom"), "snowflake", codes.Internal, reasonInternal, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(generateError(tt.err, tt.mode, "order"))
+			if st.Code() != tt.code {
+				t.Fatalf("code = %v, want %v", st.Code(), tt.code)
+			}
+			if reason := errorReason(st); reason != tt.reason {
+				t.Fatalf("reason = %q, want %q", reason, tt.reason)
+			}
+			if delay := retryDelay(st); delay != tt.retry {
+				t.Fatalf("retry delay = %v, want %v", delay, tt.retry)
+			}
+			info := st.Details()[0].(*errdetails.ErrorInfo)
+			if info.Domain != errorDomain || info.Metadata["mode"] != tt.mode || info.Metadata["biz_tag"] != "order" {
+				t.Fatalf("ErrorInfo = %v", info)
+			}
+		})
+	}
+}
+
+func TestServerErrorCodes(t *testing.T) {
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 1000, "slow": 1000})
+	s := newTestServer(t, alloc, 50*time.Millisecond)
+	ctx := context.Background()
+
+	_, err := s.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "uuid"})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("invalid mode: code = %v, want InvalidArgument", st.Code())
+	}
+	violation := st.Details()[0].(*errdetails.BadRequest).FieldViolations[0]
+	if violation.Field != "mode" {
+		t.Fatalf("invalid mode: field = %q, want mode", violation.Field)
+	}
+
+	_, err = s.MakeIDBatch(ctx, &pb.MakeIDBatchRequest{Mode: "snowflake", Count: 1001})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("count too large: code = %v, want InvalidArgument", code)
+	}
+
+	_, err = s.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "segment", BizTag: "missing"})
+	if st := status.Convert(err); st.Code() != codes.NotFound || errorReason(st) != reasonBizTagNotFound {
+		t.Fatalf("unknown biz_tag: %v, want NotFound with %s", err, reasonBizTagNotFound)
+	}
+
+	// 存储后端慢于 wait_timeout 时 Buffer 为空
+	alloc.SetLatency(time.Second)
+	_, err = s.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "segment", BizTag: "slow"})
+	if st := status.Convert(err); st.Code() != codes.ResourceExhausted || errorReason(st) != reasonBufferEmpty || retryDelay(st) != bufferRetryDelay {
+		t.Fatalf("empty buffer: %v, want ResourceExhausted with RetryInfo", err)
+	}
+}
+
+func TestServerSegmentDisabled(t *testing.T) {
+	s := newTestServer(t, nil, time.Second)
+
+	_, err := s.MakeIDService(context.Background(), &pb.MakeIDServiceRequest{Mode: "segment"})
+	if st := status.Convert(err); st.Code() != codes.FailedPrecondition || errorReason(st) != reasonSegmentDisabled {
+		t.Fatalf("segment disabled: %v, want FailedPrecondition with %s", err, reasonSegmentDisabled)
+	}
+}
+
+func TestServerShuttingDown(t *testing.T) {
+	s := newTestServer(t, nil, time.Second)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Shutdown 后 Buffer 中可能还有 ID，取空之后返回关闭错误
+	for i := 0; i < 200; i++ {
+		_, err := s.MakeIDService(context.Background(), &pb.MakeIDServiceRequest{Mode: "snowflake"})
+		if err == nil {
+			continue
+		}
+		if st := status.Convert(err); st.Code() != codes.Unavailable || errorReason(st) != reasonShuttingDown {
+			t.Fatalf("after shutdown: %v, want Unavailable with %s", err, reasonShuttingDown)
+		}
+		return
+	}
+	t.Fatal("MakeIDService kept succeeding after shutdown")
+}
@@ -2,47 +2,64 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"strings"
 	"time"
 
-	"github.com/mazezen/mid/proto/pb"
+	"github.com/mazezen/mid/client"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 )
 
 func main() {
-	conn, err := grpc.Dial(
-		"localhost:50051", 
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-   		grpc.WithBlock(),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Time:                10 * time.Second, // 发送 ping 的间隔
-			Timeout:             10 * time.Second,  // 等待 ping 响应的超时
-			PermitWithoutStream: true,             // 允许无活跃流时发送 ping
-		}),
-	)
+	// 逗号分隔的节点列表，或者一个 dns:///host:port 目标
+	endpoints := flag.String("endpoints", "localhost:50051", "comma separated mid endpoints, or a single dns:///host:port target")
+	flag.Parse()
+
+	conn, err := client.Dial(strings.Split(*endpoints, ","), client.DialOptions{
+		GRPCOptions: []grpc.DialOption{
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                10 * time.Second, // 发送 ping 的间隔
+				Timeout:             10 * time.Second, // 等待 ping 响应的超时
+				PermitWithoutStream: true,             // 允许无活跃流时发送 ping
+			}),
+		},
+	})
 	if err != nil {
 		log.Fatalf("failed to connect :%v", err)
 	}
 	defer conn.Close()
 
-	client := pb.NewIDMakerClient(conn)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// 测试 Snowflake 模式
-	resp, err := client.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "snowflake"})
+	id, err := conn.NextID(ctx, "snowflake", "")
 	if err != nil {
 		log.Fatalf("failed to generate snowflake ID: %v", err)
 	}
-	log.Printf("Snowflake ID: %d", resp.Id)
+	log.Printf("Snowflake ID: %d (node %s)", id.Value, id.Node)
 
 	// 测试 Segment 模式
-	resp, err = client.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "segment"})
+	id, err = conn.NextID(ctx, "segment", "")
 	if err != nil {
 		log.Fatalf("failed to generate segment ID: %v", err)
 	}
-	log.Printf("Segment ID: %d", resp.Id)
-}
\ No newline at end of file
+	log.Printf("Segment ID: %d (node %s)", id.Value, id.Node)
+
+	// 本地租用 ID，大部分调用不需要网络往返
+	leaser, err := client.New(conn.IDMaker(), client.Options{Mode: "segment"})
+	if err != nil {
+		log.Fatalf("failed to create leasing client: %v", err)
+	}
+	defer leaser.Close()
+	for i := 0; i < 3; i++ {
+		value, err := leaser.NextID(ctx)
+		if err != nil {
+			log.Fatalf("failed to generate leased segment ID: %v", err)
+		}
+		log.Printf("Leased segment ID: %d", value)
+	}
+	log.Printf("Local hit rate: %.2f", leaser.Stats().HitRate())
+}
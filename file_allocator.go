@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 日志文件格式：文件头 fileLogMagic，之后是若干条记录，每条记录为
+//
+//	crc32c (4) | tag 长度 (2) | max_id (8) | tag
+//
+// 整数均为小端序，crc32c 覆盖 crc 之后的全部字节
+var fileLogMagic = []byte("MIDSEG1\n")
+
+const fileRecordHeaderSize = 4 + 2 + 8
+
+// maxBizTagLen 业务标识的最大字节数，日志中声明的 tag 长度超过它说明记录已损坏
+const maxBizTagLen = 255
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// fileAllocator 单节点持久化的号段存储后端，不依赖外部数据库
+//
+// 每次分配号段时将业务标识的新 max_id 追加写入本地日志并 fsync，写入成功后才返回号段；
+// 启动时重放日志恢复每个业务标识的最大 max_id，崩溃后也不会重复发号。
+// 日志超过 compactSize 后压缩为每个业务标识一条记录。同一日志文件只能由一个进程使用。
+type fileAllocator struct {
+	path        string
+	compactSize int64
+	steps       map[string]int64 // 配置的业务标识及其基础步长
+
+	lock *os.File // 进程存活期间持有 path.lock 的排他锁
+
+	mu           sync.Mutex
+	file         *os.File
+	size         int64
+	snapshotSize int64 // 上次压缩后的日志大小
+	maxIDs       map[string]int64
+	err          error // 写入或 fsync 失败后文件状态未知，拒绝继续分配
+}
+
+// openFileAllocator 打开（不存在时创建）日志文件并恢复各业务标识的 max_id
+func openFileAllocator(cfg FileConfig) (*fileAllocator, error) {
+	steps, err := parseBizTags(cfg.BizTags, cfg.Step)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create segment log directory: %v", err)
+	}
+	lock, err := os.OpenFile(cfg.Path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment log lock: %v", err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("segment log %s is in use by another process: %v", cfg.Path, err)
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to open segment log: %v", err)
+	}
+	a := &fileAllocator{
+		path:        cfg.Path,
+		compactSize: cfg.CompactSize,
+		steps:       steps,
+		lock:        lock,
+		file:        file,
+		maxIDs:      make(map[string]int64),
+	}
+	if err := a.recover(); err != nil {
+		a.file.Close()
+		lock.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// parseBizTags 解析 "default,order:5000" 形式的业务标识列表，未指定步长的使用 step
+func parseBizTags(s string, step int64) (map[string]int64, error) {
+	steps := make(map[string]int64)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		tag, stepStr, ok := strings.Cut(item, ":")
+		tagStep := step
+		if ok {
+			n, err := strconv.ParseInt(stepStr, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step for biz_tag %q", tag)
+			}
+			tagStep = n
+		}
+		if tag == "" || len(tag) > maxBizTagLen {
+			return nil, fmt.Errorf("invalid biz_tag %q", tag)
+		}
+		steps[tag] = tagStep
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("no biz_tag configured")
+	}
+	return steps, nil
+}
+
+// recover 重放日志。只有文件最后一条记录不完整或校验失败时，才是写入时崩溃留下的，截断后继续；
+// 之后还有记录的损坏（包括声明的长度越界）说明文件被破坏，此时无法确定已分配的最大 ID，拒绝启动
+func (a *fileAllocator) recover() error {
+	data, err := io.ReadAll(a.file)
+	if err != nil {
+		return fmt.Errorf("failed to read segment log: %v", err)
+	}
+	if len(data) == 0 {
+		return a.rewrite()
+	}
+	if !bytes.HasPrefix(data, fileLogMagic) {
+		return fmt.Errorf("%s is not a segment log", a.path)
+	}
+
+	offset := len(fileLogMagic)
+	records := 0
+	for offset < len(data) {
+		tag, maxID, n, ok := decodeFileRecord(data[offset:])
+		if !ok {
+			if !isTornTail(data[offset:], n) {
+				return fmt.Errorf("segment log %s is corrupted at offset %d", a.path, offset)
+			}
+			mLog.Warn("Truncating incomplete segment log record",
+				zap.String("path", a.path),
+				zap.Int("offset", offset),
+				zap.Int("discarded_bytes", len(data)-offset))
+			if err := a.file.Truncate(int64(offset)); err != nil {
+				return fmt.Errorf("failed to truncate segment log: %v", err)
+			}
+			if err := a.file.Sync(); err != nil {
+				return fmt.Errorf("failed to sync segment log: %v", err)
+			}
+			break
+		}
+		a.maxIDs[tag] = max(a.maxIDs[tag], maxID)
+		offset += n
+		records++
+	}
+	a.size = int64(offset)
+	mLog.Info("Recovered segment log",
+		zap.String("path", a.path),
+		zap.Int("records", records),
+		zap.Int("biz_tags", len(a.maxIDs)))
+	return nil
+}
+
+// isTornTail 判断从解析失败的记录开始的 rest 是否为写入时崩溃留下的末尾：崩溃只会留下一条记录的前缀，
+// 其后不会再有完整的记录。n 为记录头声明的长度；部分文件系统崩溃后末尾全零，同样按不完整的记录处理
+func isTornTail(rest []byte, n int) bool {
+	if len(rest) < fileRecordHeaderSize || allZero(rest) {
+		return true
+	}
+	if n-fileRecordHeaderSize > maxBizTagLen {
+		return false
+	}
+	if n < len(rest) && !allZero(rest[n:]) {
+		return false
+	}
+	// 声明的长度越界时，之后仍能解析出完整的记录说明长度字段本身已损坏
+	for i := 1; i+fileRecordHeaderSize <= len(rest); i++ {
+		if _, _, _, ok := decodeFileRecord(rest[i:]); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allZero 部分文件系统崩溃后会在文件末尾留下全零的数据块，按不完整的记录处理
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeFileRecord 解析一条记录，返回记录长度 n；ok 为 false 时 n 为记录头声明的长度（可能超过 len(b)），
+// 记录头不完整时为 0
+func decodeFileRecord(b []byte) (tag string, maxID int64, n int, ok bool) {
+	if len(b) < fileRecordHeaderSize {
+		return "", 0, 0, false
+	}
+	n = fileRecordHeaderSize + int(binary.LittleEndian.Uint16(b[4:6]))
+	if len(b) < n || n-fileRecordHeaderSize > maxBizTagLen {
+		return "", 0, n, false
+	}
+	if crc32.Checksum(b[4:n], crc32c) != binary.LittleEndian.Uint32(b[0:4]) {
+		return "", 0, n, false
+	}
+	return string(b[fileRecordHeaderSize:n]), int64(binary.LittleEndian.Uint64(b[6:14])), n, true
+}
+
+func encodeFileRecord(buf []byte, tag string, maxID int64) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(tag)))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(maxID))
+	buf = append(buf, tag...)
+	binary.LittleEndian.PutUint32(buf[start:], crc32.Checksum(buf[start+4:], crc32c))
+	return buf
+}
+
+// rewrite 将当前状态写入临时文件，fsync 后原子替换日志，用于初始化和压缩
+func (a *fileAllocator) rewrite() error {
+	tags := make([]string, 0, len(a.maxIDs))
+	for tag := range a.maxIDs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	buf := append([]byte(nil), fileLogMagic...)
+	for _, tag := range tags {
+		buf = encodeFileRecord(buf, tag, a.maxIDs[tag])
+	}
+
+	tmp := a.path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return err
+	}
+	// 替换后 a.file 指向已被 unlink 的旧文件，追加的记录不会出现在新日志中，
+	// 之后任何一步失败都拒绝继续分配
+	if err := syncDir(filepath.Dir(a.path)); err != nil {
+		a.err = fmt.Errorf("failed to sync segment log directory: %v", err)
+		return a.err
+	}
+
+	// 重新打开替换后的日志，之后的记录追加到新文件
+	file, err = os.OpenFile(a.path, os.O_RDWR, 0o644)
+	if err != nil {
+		a.err = fmt.Errorf("failed to reopen segment log: %v", err)
+		return a.err
+	}
+	a.file.Close()
+	a.file = file
+	a.size = int64(len(buf))
+	a.snapshotSize = a.size
+	return nil
+}
+
+// syncDir fsync 目录，保证 rename 持久化。测试中替换以模拟失败
+var syncDir = func(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (a *fileAllocator) Backend() string {
+	return backendFile
+}
+
+func (a *fileAllocator) Step(ctx context.Context, bizTag string) (int64, error) {
+	step, ok := a.steps[bizTag]
+	if !ok {
+		return 0, errBizTagNotFound
+	}
+	return step, nil
+}
+
+func (a *fileAllocator) Reserve(ctx context.Context, bizTag string, step int64) (int64, error) {
+	if _, ok := a.steps[bizTag]; !ok {
+		return 0, errBizTagNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	newMax := a.maxIDs[bizTag] + step
+	record := encodeFileRecord(nil, bizTag, newMax)
+	if _, err := a.file.WriteAt(record, a.size); err != nil {
+		a.err = fmt.Errorf("failed to append segment log: %v", err)
+		return 0, a.err
+	}
+	if err := a.file.Sync(); err != nil {
+		a.err = fmt.Errorf("failed to sync segment log: %v", err)
+		return 0, a.err
+	}
+	a.size += int64(len(record))
+	a.maxIDs[bizTag] = newMax
+
+	if a.size-a.snapshotSize > a.compactSize {
+		before := a.size
+		if err := a.rewrite(); err != nil {
+			// 压缩失败不影响已写入的记录，新日志已替换时 rewrite 设置了 a.err，否则继续追加
+			mLog.Error("Failed to compact segment log", zap.String("path", a.path), zap.Error(err))
+		} else {
+			mLog.Info("Compacted segment log",
+				zap.String("path", a.path),
+				zap.Int64("before_bytes", before),
+				zap.Int64("after_bytes", a.size))
+		}
+	}
+	return newMax, nil
+}
+
+func (a *fileAllocator) Ping(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *fileAllocator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	a.lock.Close()
+	if a.err == nil {
+		a.err = errors.New("segment log is closed")
+	}
+	return err
+}
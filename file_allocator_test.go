@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func testFileConfig(t *testing.T) FileConfig {
+	t.Helper()
+	mLog = zap.NewNop()
+	return FileConfig{
+		Path:        filepath.Join(t.TempDir(), "segments.log"),
+		BizTags:     "default,order:50",
+		Step:        100,
+		CompactSize: 1 << 20,
+	}
+}
+
+func mustOpenFileAllocator(t *testing.T, cfg FileConfig) *fileAllocator {
+	t.Helper()
+	a, err := openFileAllocator(cfg)
+	if err != nil {
+		t.Fatalf("openFileAllocator: %v", err)
+	}
+	return a
+}
+
+func mustReserve(t *testing.T, a *fileAllocator, bizTag string, step, want int64) {
+	t.Helper()
+	got, err := a.Reserve(context.Background(), bizTag, step)
+	if err != nil {
+		t.Fatalf("Reserve(%s): %v", bizTag, err)
+	}
+	if got != want {
+		t.Fatalf("Reserve(%s) = %d, want %d", bizTag, got, want)
+	}
+}
+
+func TestFileAllocatorRecoversAfterReopen(t *testing.T) {
+	cfg := testFileConfig(t)
+	a := mustOpenFileAllocator(t, cfg)
+
+	if step, err := a.Step(context.Background(), "order"); err != nil || step != 50 {
+		t.Fatalf("Step(order) = %d, %v; want 50", step, err)
+	}
+	if _, err := a.Reserve(context.Background(), "missing", 10); !errors.Is(err, errBizTagNotFound) {
+		t.Fatalf("Reserve(missing) error = %v, want errBizTagNotFound", err)
+	}
+	mustReserve(t, a, defaultBizTag, 100, 100)
+	mustReserve(t, a, defaultBizTag, 200, 300)
+	mustReserve(t, a, "order", 50, 50)
+
+	// 第二个进程不能同时打开同一日志
+	if _, err := openFileAllocator(cfg); err == nil {
+		t.Fatal("second open of a locked segment log succeeded")
+	}
+	a.Close()
+
+	a = mustOpenFileAllocator(t, cfg)
+	defer a.Close()
+	mustReserve(t, a, defaultBizTag, 100, 400)
+	mustReserve(t, a, "order", 50, 100)
+}
+
+func TestFileAllocatorTruncatesTornTail(t *testing.T) {
+	cfg := testFileConfig(t)
+	a := mustOpenFileAllocator(t, cfg)
+	mustReserve(t, a, defaultBizTag, 100, 100)
+	a.Close()
+
+	// 模拟写入记录时崩溃：末尾只有半条记录
+	record := encodeFileRecord(nil, defaultBizTag, 200)
+	appendToFile(t, cfg.Path, record[:len(record)-3])
+	before := fileSize(t, cfg.Path)
+
+	a = mustOpenFileAllocator(t, cfg)
+	if got := fileSize(t, cfg.Path); got != before-int64(len(record)-3) {
+		t.Fatalf("log size after recovery = %d, want %d", got, before-int64(len(record)-3))
+	}
+	mustReserve(t, a, defaultBizTag, 100, 200)
+	a.Close()
+
+	// 崩溃后文件末尾全零
+	appendToFile(t, cfg.Path, make([]byte, 64))
+	a = mustOpenFileAllocator(t, cfg)
+	defer a.Close()
+	mustReserve(t, a, defaultBizTag, 100, 300)
+}
+
+func TestFileAllocatorRejectsCorruptedRecord(t *testing.T) {
+	cfg := testFileConfig(t)
+	a := mustOpenFileAllocator(t, cfg)
+	mustReserve(t, a, defaultBizTag, 100, 100)
+	mustReserve(t, a, defaultBizTag, 100, 200)
+	a.Close()
+
+	// 破坏第一条记录的 max_id，之后还有完整的记录
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(fileLogMagic)+6] ^= 0xff
+	if err := os.WriteFile(cfg.Path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openFileAllocator(cfg); err == nil {
+		t.Fatal("opened a segment log with a corrupted record")
+	}
+}
+
+// 日志中间的记录声明的长度损坏时，不能当作末尾不完整的记录截断
+func TestFileAllocatorRejectsCorruptedLength(t *testing.T) {
+	for _, tagLen := range []uint16{maxBizTagLen + 1, 0xffff, 200} {
+		cfg := testFileConfig(t)
+		a := mustOpenFileAllocator(t, cfg)
+		mustReserve(t, a, defaultBizTag, 100, 100)
+		mustReserve(t, a, defaultBizTag, 100, 200)
+		a.Close()
+
+		data, err := os.ReadFile(cfg.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.LittleEndian.PutUint16(data[len(fileLogMagic)+4:], tagLen)
+		if err := os.WriteFile(cfg.Path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openFileAllocator(cfg); err == nil {
+			t.Fatalf("tag length %d: opened a segment log with a corrupted record", tagLen)
+		}
+		if got := fileSize(t, cfg.Path); got != int64(len(data)) {
+			t.Fatalf("tag length %d: log truncated to %d bytes, want %d", tagLen, got, len(data))
+		}
+	}
+}
+
+func TestParseBizTagsLimitsLength(t *testing.T) {
+	if _, err := parseBizTags(strings.Repeat("a", maxBizTagLen), 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseBizTags(strings.Repeat("a", maxBizTagLen+1), 10); err == nil {
+		t.Fatalf("accepted a biz_tag longer than %d bytes", maxBizTagLen)
+	}
+}
+
+func TestFileAllocatorCompaction(t *testing.T) {
+	cfg := testFileConfig(t)
+	cfg.CompactSize = 256
+	a := mustOpenFileAllocator(t, cfg)
+	for i := int64(1); i <= 100; i++ {
+		mustReserve(t, a, defaultBizTag, 10, i*10)
+		mustReserve(t, a, "order", 5, i*5)
+	}
+	if size := fileSize(t, cfg.Path); size > int64(len(fileLogMagic))+cfg.CompactSize+64 {
+		t.Fatalf("log size %d not compacted", size)
+	}
+	a.Close()
+
+	a = mustOpenFileAllocator(t, cfg)
+	defer a.Close()
+	mustReserve(t, a, defaultBizTag, 10, 1010)
+	mustReserve(t, a, "order", 5, 505)
+}
+
+// 日志替换后 fsync 目录失败时，旧文件句柄已不是日志文件，之后的分配必须失败
+func TestFileAllocatorFailsAfterRenameError(t *testing.T) {
+	cfg := testFileConfig(t)
+	cfg.CompactSize = 64
+	a := mustOpenFileAllocator(t, cfg)
+	defer a.Close()
+
+	injected := errors.New("injected sync failure")
+	orig := syncDir
+	syncDir = func(string) error { return injected }
+	defer func() { syncDir = orig }()
+
+	var last int64
+	for i := int64(1); ; i++ {
+		got, err := a.Reserve(context.Background(), defaultBizTag, 10)
+		if err != nil {
+			if !strings.Contains(err.Error(), injected.Error()) {
+				t.Fatalf("Reserve = %v, want the sync failure", err)
+			}
+			break
+		}
+		if i > 100 {
+			t.Fatal("Reserve kept succeeding after the failed compaction")
+		}
+		last = got
+	}
+	if err := a.Ping(context.Background()); err == nil {
+		t.Fatal("Ping succeeded after the log was replaced behind the open file")
+	}
+	a.Close()
+	syncDir = orig
+
+	// 已返回的号段都在替换后的日志中
+	a = mustOpenFileAllocator(t, cfg)
+	defer a.Close()
+	mustReserve(t, a, defaultBizTag, 10, last+10)
+}
+
+func appendToFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile 非 Unix 平台不加锁，需自行保证同一日志文件只由一个进程使用
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gateway 将 IDMaker 的接口以 HTTP/JSON 暴露给无法使用 gRPC 的调用方。
+// 请求直接调用 gRPC 服务的实现，校验规则和错误码与 gRPC 接口一致。
+// ID 以字符串返回，避免 JavaScript 等只支持 53 位整数精度的调用方丢失精度
+//
+//	GET  /v1/id?mode=segment&biz_tag=order   -> {"id": "1001"}
+//	POST /v1/ids:batch {"mode": "snowflake", "count": 10} -> {"ids": ["...", ...]}
+//	GET  /v1/decode?id=578779521390612487    -> {"timestamp": ..., "valid": true, ...}
+type gateway struct {
+	srv *server
+
+	mu       sync.RWMutex
+	closed   bool // Shutdown 之后拒绝新请求
+	inflight sync.WaitGroup
+}
+
+func newGateway(srv *server) *gateway {
+	return &gateway{srv: srv}
+}
+
+// register 在 mux 上注册网关的路由
+func (g *gateway) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/id", g.track(g.handleID))
+	mux.HandleFunc("POST /v1/ids:batch", g.track(g.handleBatch))
+	mux.HandleFunc("GET /v1/decode", g.track(g.handleDecode))
+}
+
+// track 记录进行中的请求，Shutdown 之后的请求直接返回 SHUTTING_DOWN
+func (g *gateway) track(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g.mu.RLock()
+		if g.closed {
+			g.mu.RUnlock()
+			writeError(w, errorWithInfo(codes.Unavailable, "server is shutting down", reasonShuttingDown, nil, 0))
+			return
+		}
+		g.inflight.Add(1)
+		g.mu.RUnlock()
+		defer g.inflight.Done()
+		h(w, r)
+	}
+}
+
+// Shutdown 停止接收网关请求并等待进行中的请求完成。网关与指标服务共用端口时，
+// 指标和 /healthz 在此之后仍可访问
+func (g *gateway) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type idResponse struct {
+	ID string `json:"id"`
+}
+
+func (g *gateway) handleID(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	resp, err := g.srv.MakeIDService(r.Context(), &pb.MakeIDServiceRequest{
+		Mode:   query.Get("mode"),
+		BizTag: query.Get("biz_tag"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, idResponse{ID: strconv.FormatInt(resp.Id, 10)})
+}
+
+type batchRequest struct {
+	Mode   string `json:"mode"`
+	BizTag string `json:"biz_tag"`
+	Count  int32  `json:"count"`
+}
+
+type batchResponse struct {
+	IDs []string `json:"ids"`
+}
+
+func (g *gateway) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, invalidArgument("body", "invalid request body: %v", err))
+		return
+	}
+	resp, err := g.srv.MakeIDBatch(r.Context(), &pb.MakeIDBatchRequest{
+		Mode:   req.Mode,
+		BizTag: req.BizTag,
+		Count:  req.Count,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ids := make([]string, len(resp.Ids))
+	for i, id := range resp.Ids {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	writeJSON(w, http.StatusOK, batchResponse{IDs: ids})
+}
+
+type decodeResponse struct {
+	Timestamp    int64  `json:"timestamp"`
+	DatacenterID int64  `json:"datacenter_id"`
+	MachineID    int64  `json:"machine_id"`
+	Sequence     int64  `json:"sequence"`
+	Valid        bool   `json:"valid"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+func (g *gateway) handleDecode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, invalidArgument("id", "id must be a 64-bit integer"))
+		return
+	}
+	resp, err := g.srv.DecodeID(r.Context(), &pb.DecodeIDRequest{Id: id})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, decodeResponse{
+		Timestamp:    resp.Timestamp,
+		DatacenterID: resp.DatacenterId,
+		MachineID:    resp.MachineId,
+		Sequence:     resp.Sequence,
+		Valid:        resp.Valid,
+		Reason:       resp.Reason,
+	})
+}
+
+type errorResponse struct {
+	Code    string `json:"code"` // gRPC 状态码名称，例如 INVALID_ARGUMENT
+	Message string `json:"message"`
+	Reason  string `json:"reason,omitempty"` // ErrorInfo 的 reason，例如 CLOCK_ROLLBACK
+}
+
+// writeError 按 gRPC 状态码返回对应的 HTTP 状态码，RetryInfo 转换为 Retry-After 头（向上取整到秒）
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	if delay := retryDelay(st); delay > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((delay+time.Second-1)/time.Second), 10))
+	}
+	writeJSON(w, httpStatusFromCode(st.Code()), errorResponse{
+		Code:    codeName(st.Code()),
+		Message: st.Message(),
+		Reason:  errorReason(st),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, httpStatus int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		mLog.Debug("Failed to write gateway response", zap.Error(err))
+	}
+}
+
+// httpStatusFromCode gRPC 状态码到 HTTP 状态码的映射，与 grpc-gateway 一致
+func httpStatusFromCode(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // 客户端关闭连接
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// codeName 返回 proto 中定义的状态码名称，例如 INVALID_ARGUMENT
+func codeName(c codes.Code) string {
+	if name, ok := code.Code_name[int32(c)]; ok {
+		return name
+	}
+	return c.String()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGateway(t *testing.T) *httptest.Server {
+	t.Helper()
+	s := newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second)
+	mux := http.NewServeMux()
+	newGateway(s).register(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// doJSON 发送请求并解析 JSON 响应，返回 HTTP 状态码
+func doJSON(t *testing.T, method, url, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestGatewayID(t *testing.T) {
+	ts := newTestGateway(t)
+
+	var resp idResponse
+	if code := doJSON(t, "GET", ts.URL+"/v1/id?mode=snowflake", "", &resp); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	id, err := strconv.ParseInt(resp.ID, 10, 64)
+	if err != nil {
+		t.Fatalf("id %q is not an integer string: %v", resp.ID, err)
+	}
+	if parts := DecodeID(id); parts.DatacenterID != 3 || parts.MachineID != 4 {
+		t.Fatalf("id %d decodes to %+v", id, parts)
+	}
+
+	var decoded decodeResponse
+	if code := doJSON(t, "GET", ts.URL+"/v1/decode?id="+resp.ID, "", &decoded); code != http.StatusOK {
+		t.Fatalf("decode status = %d, want 200", code)
+	}
+	if !decoded.Valid || decoded.MachineID != 4 {
+		t.Fatalf("decode = %+v", decoded)
+	}
+
+	if code := doJSON(t, "GET", ts.URL+"/v1/id?mode=segment", "", &resp); code != http.StatusOK || resp.ID != "1" {
+		t.Fatalf("segment id = %q (status %d), want \"1\"", resp.ID, code)
+	}
+}
+
+func TestGatewayBatch(t *testing.T) {
+	ts := newTestGateway(t)
+
+	var resp batchResponse
+	code := doJSON(t, "POST", ts.URL+"/v1/ids:batch", `{"mode": "segment", "biz_tag": "default", "count": 150}`, &resp)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(resp.IDs) != 150 || resp.IDs[0] != "1" || resp.IDs[149] != "150" {
+		t.Fatalf("ids = %v", resp.IDs)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	ts := newTestGateway(t)
+
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		code                     string
+	}{
+		{"count too large", "POST", "/v1/ids:batch", `{"mode": "snowflake", "count": 1001}`, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"unknown field", "POST", "/v1/ids:batch", `{"mode": "snowflake", "count": 1, "size": 2}`, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"malformed body", "POST", "/v1/ids:batch", `{"mode":`, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"unknown biz_tag", "GET", "/v1/id?mode=segment&biz_tag=missing", "", http.StatusNotFound, "NOT_FOUND"},
+		{"invalid mode", "GET", "/v1/id?mode=uuid", "", http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"invalid decode id", "GET", "/v1/decode?id=abc", "", http.StatusBadRequest, "INVALID_ARGUMENT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp errorResponse
+			if code := doJSON(t, tt.method, ts.URL+tt.path, tt.body, &resp); code != tt.status {
+				t.Fatalf("status = %d, want %d (%+v)", code, tt.status, resp)
+			}
+			if resp.Code != tt.code || resp.Message == "" {
+				t.Fatalf("error = %+v, want code %s", resp, tt.code)
+			}
+		})
+	}
+
+	if code := doJSON(t, "POST", ts.URL+"/v1/id?mode=snowflake", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /v1/id status = %d, want 405", code)
+	}
+}
+
+func TestGatewayShutdown(t *testing.T) {
+	s := newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second)
+	gw := newGateway(s)
+	mux := http.NewServeMux()
+	gw.register(mux)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	if err := gw.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var errResp errorResponse
+	if code := doJSON(t, "GET", ts.URL+"/v1/id?mode=snowflake", "", &errResp); code != http.StatusServiceUnavailable || errResp.Reason != reasonShuttingDown {
+		t.Fatalf("after shutdown: %d %+v, want 503 %s", code, errResp, reasonShuttingDown)
+	}
+	// 共用端口上的其他路由不受影响
+	if code := doJSON(t, "GET", ts.URL+"/healthz", "", nil); code != http.StatusOK {
+		t.Fatalf("healthz after gateway shutdown = %d, want 200", code)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 健康检查的服务名，"" 表示整个节点
+const (
+	healthServiceSnowflake = "snowflake"
+	healthServiceSegment   = "segment"
+)
+
+// healthChecker 定期检查各模式能否发号，并同步到 grpc.health.v1 服务和 HTTP /healthz
+//
+//   - snowflake：租约丢失或时钟回退超过容忍范围时为 NOT_SERVING
+//   - segment：号段存储后端不可达且所有 Buffer 为空时为 NOT_SERVING
+//   - ""：任一模式可用即为 SERVING
+type healthChecker struct {
+	srv      *server
+	health   *health.Server
+	interval time.Duration
+
+	mu       sync.Mutex
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newHealthChecker(srv *server, healthServer *health.Server, interval time.Duration) *healthChecker {
+	return &healthChecker{
+		srv:      srv,
+		health:   healthServer,
+		interval: interval,
+		statuses: make(map[string]healthpb.HealthCheckResponse_ServingStatus),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 立即检查一次，之后每 interval 检查一次
+func (h *healthChecker) Start() {
+	h.check()
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.done:
+				return
+			case <-ticker.C:
+				h.check()
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) check() {
+	snowflake := healthpb.HealthCheckResponse_SERVING
+	if h.srv.snowflake == nil {
+		snowflake = healthpb.HealthCheckResponse_NOT_SERVING
+	} else if err := h.srv.snowflake.Healthy(); err != nil {
+		snowflake = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	segment := healthpb.HealthCheckResponse_NOT_SERVING
+	if h.srv.alloc != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+		err := h.srv.alloc.Ping(ctx)
+		cancel()
+		if err == nil || h.srv.segmentBuffered() > 0 {
+			segment = healthpb.HealthCheckResponse_SERVING
+		}
+	}
+
+	overall := healthpb.HealthCheckResponse_NOT_SERVING
+	if snowflake == healthpb.HealthCheckResponse_SERVING || segment == healthpb.HealthCheckResponse_SERVING {
+		overall = healthpb.HealthCheckResponse_SERVING
+	}
+
+	h.set(healthServiceSnowflake, snowflake)
+	h.set(healthServiceSegment, segment)
+	h.set("", overall)
+}
+
+// set 更新状态，状态变化时记录日志
+func (h *healthChecker) set(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	prev, ok := h.statuses[service]
+	h.statuses[service] = status
+	h.mu.Unlock()
+
+	if !ok || prev != status {
+		mLog.Info("Health status changed",
+			zap.String("service", service),
+			zap.String("status", status.String()))
+	}
+	h.health.SetServingStatus(service, status)
+}
+
+// ServeHTTP 以 JSON 返回健康状态，?service= 指定服务，不可用时返回 503
+func (h *healthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+
+	h.mu.Lock()
+	status, ok := h.statuses[service]
+	services := make(map[string]string, len(h.statuses))
+	for name, s := range h.statuses {
+		if name != "" {
+			services[name] = s.String()
+		}
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		status = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	code := http.StatusOK
+	if status != healthpb.HealthCheckResponse_SERVING {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":   status.String(),
+		"services": services,
+	})
+}
+
+// Shutdown 停止检查并将所有服务置为 NOT_SERVING，让负载均衡尽快摘除节点
+func (h *healthChecker) Shutdown() {
+	close(h.done)
+	h.wg.Wait()
+
+	h.mu.Lock()
+	for service := range h.statuses {
+		h.statuses[service] = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.mu.Unlock()
+	h.health.Shutdown()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// downAllocator Ping 可切换失败的号段存储后端，模拟后端不可达
+type downAllocator struct {
+	*memoryAllocator
+	down atomic.Bool
+}
+
+func newDownAllocator(down bool) *downAllocator {
+	a := &downAllocator{memoryAllocator: newMemoryAllocator(map[string]int64{defaultBizTag: 1000})}
+	a.down.Store(down)
+	return a
+}
+
+func (a *downAllocator) Ping(ctx context.Context) error {
+	if a.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// checkHealth 对 s 执行一次检查，返回各服务在 grpc.health.v1 中的状态
+func checkHealth(t *testing.T, s *server) (*healthChecker, map[string]healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	healthServer := health.NewServer()
+	h := newHealthChecker(s, healthServer, time.Second)
+	h.check()
+	statuses := make(map[string]healthpb.HealthCheckResponse_ServingStatus)
+	for _, service := range []string{"", healthServiceSnowflake, healthServiceSegment} {
+		resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses[service] = resp.Status
+	}
+	return h, statuses
+}
+
+func TestHealthCheck(t *testing.T) {
+	const (
+		serving    = healthpb.HealthCheckResponse_SERVING
+		notServing = healthpb.HealthCheckResponse_NOT_SERVING
+	)
+	lostLease := func(t *testing.T) *Snowflake {
+		s, err := NewSnowflake(3, 4, DefaultLayout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.registry = &WorkerRegistry{lost: true}
+		return s
+	}
+	healthy := func(t *testing.T) *Snowflake {
+		return newTestSnowflake(t, DefaultLayout)
+	}
+	upBackend := func() segmentAllocator { return newDownAllocator(false) }
+	downBackend := func() segmentAllocator { return newDownAllocator(true) }
+
+	tests := []struct {
+		name      string
+		snowflake func(t *testing.T) *Snowflake
+		alloc     func() segmentAllocator
+		snow, seg healthpb.HealthCheckResponse_ServingStatus
+		overall   healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{"all healthy", healthy, upBackend, serving, serving, serving},
+		{"segment disabled", healthy, func() segmentAllocator { return nil }, serving, notServing, serving},
+		{"backend down with empty buffer", healthy, downBackend, serving, notServing, serving},
+		{"lease lost", lostLease, upBackend, notServing, serving, serving},
+		{"lease lost and backend down", lostLease, downBackend, notServing, notServing, notServing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServerWith(t, tt.snowflake(t), tt.alloc(), time.Second)
+			_, statuses := checkHealth(t, s)
+			if statuses[healthServiceSnowflake] != tt.snow || statuses[healthServiceSegment] != tt.seg || statuses[""] != tt.overall {
+				t.Fatalf("statuses = %v, want snowflake %v, segment %v, overall %v", statuses, tt.snow, tt.seg, tt.overall)
+			}
+		})
+	}
+}
+
+// 存储后端不可达但 Buffer 中还有 ID 时仍可发号
+func TestHealthCheckSegmentServesFromBuffer(t *testing.T) {
+	alloc := newDownAllocator(false)
+	s := newTestServer(t, alloc, time.Second)
+	if _, err := s.segmentBuffer(defaultBizTag); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return s.segmentBuffered() > 0 })
+
+	alloc.down.Store(true)
+	if _, statuses := checkHealth(t, s); statuses[healthServiceSegment] != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("segment = %v with %d buffered ids, want SERVING", statuses[healthServiceSegment], s.segmentBuffered())
+	}
+}
+
+func TestHealthzQuery(t *testing.T) {
+	s := newTestServerWith(t, newTestSnowflake(t, DefaultLayout), newDownAllocator(true), time.Second)
+	h, _ := checkHealth(t, s)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	tests := []struct {
+		query  string
+		code   int
+		status string
+	}{
+		{"", http.StatusOK, "SERVING"},
+		{"?service=snowflake", http.StatusOK, "SERVING"},
+		{"?service=segment", http.StatusServiceUnavailable, "NOT_SERVING"},
+		{"?service=uuid", http.StatusServiceUnavailable, "SERVICE_UNKNOWN"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(ts.URL + "/healthz" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Status   string            `json:"status"`
+			Services map[string]string `json:"services"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.code || body.Status != tt.status {
+			t.Fatalf("/healthz%s = %d %s, want %d %s", tt.query, resp.StatusCode, body.Status, tt.code, tt.status)
+		}
+		if body.Services[healthServiceSegment] != "NOT_SERVING" || body.Services[healthServiceSnowflake] != "SERVING" {
+			t.Fatalf("/healthz%s services = %v", tt.query, body.Services)
+		}
+	}
+
+	// 关闭后所有服务为 NOT_SERVING
+	h.Shutdown()
+	resp, err := http.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("/healthz after shutdown = %d, want 503", resp.StatusCode)
+	}
+}
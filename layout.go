@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout Snowflake ID 的位布局：符号位 | 时间戳 | 数据中心 ID | 机器 ID | 序列号
+type Layout struct {
+	Epoch          time.Time     // 时间戳起点
+	TimeUnit       time.Duration // 时间戳单位，毫秒的整数倍
+	TimestampBits  int
+	DatacenterBits int
+	MachineBits    int
+	SequenceBits   int
+}
+
+// DefaultLayout 默认布局 41/5/5/12，时间单位 1 毫秒
+var DefaultLayout = Layout{
+	Epoch:          time.UnixMilli(1609459200000).UTC(), // 2021-01-01 00:00:00 UTC
+	TimeUnit:       time.Millisecond,
+	TimestampBits:  41,
+	DatacenterBits: 5,
+	MachineBits:    5,
+	SequenceBits:   12,
+}
+
+// Validate 校验布局：各部分位数之和不超过 63，时间单位为毫秒的整数倍，且当前时间仍在时间戳可表示的范围内
+func (l Layout) Validate() error {
+	if l.TimestampBits <= 0 || l.SequenceBits <= 0 || l.DatacenterBits < 0 || l.MachineBits < 0 {
+		return fmt.Errorf("timestamp and sequence bits must be positive, datacenter and machine bits must not be negative")
+	}
+	if total := l.TimestampBits + l.DatacenterBits + l.MachineBits + l.SequenceBits; total > 63 {
+		return fmt.Errorf("layout uses %d bits, at most 63 are available", total)
+	}
+	if l.TimeUnit < time.Millisecond || l.TimeUnit%time.Millisecond != 0 {
+		return fmt.Errorf("time unit must be a positive multiple of 1ms")
+	}
+	if l.Epoch.UnixMilli() < 0 || l.Epoch.After(time.Now()) {
+		return fmt.Errorf("epoch must be between 1970-01-01 and now")
+	}
+	if elapsed := l.ticks(time.Now().UnixMilli()) - l.epochTicks(); elapsed > l.maxTimestamp() {
+		return fmt.Errorf("timestamp bits exhausted: %d units elapsed since epoch, at most %d", elapsed, l.maxTimestamp())
+	}
+	return nil
+}
+
+func (l Layout) unitMillis() int64 {
+	return l.TimeUnit.Milliseconds()
+}
+
+// ticks 将 Unix 毫秒换算为时间单位
+func (l Layout) ticks(millis int64) int64 {
+	return millis / l.unitMillis()
+}
+
+func (l Layout) epochTicks() int64 {
+	return l.ticks(l.Epoch.UnixMilli())
+}
+
+func (l Layout) maxTimestamp() int64 {
+	return -1 ^ (-1 << l.TimestampBits)
+}
+
+func (l Layout) MaxDatacenter() int64 {
+	return -1 ^ (-1 << l.DatacenterBits)
+}
+
+func (l Layout) MaxMachine() int64 {
+	return -1 ^ (-1 << l.MachineBits)
+}
+
+func (l Layout) maxSequence() int64 {
+	return -1 ^ (-1 << l.SequenceBits)
+}
+
+func (l Layout) timestampShift() int {
+	return l.DatacenterBits + l.MachineBits + l.SequenceBits
+}
+
+func (l Layout) datacenterShift() int {
+	return l.MachineBits + l.SequenceBits
+}
+
+func (l Layout) machineShift() int {
+	return l.SequenceBits
+}
+
+// compose 按布局拼接 ID，tick 为自 Unix 纪元起的时间单位数
+func (l Layout) compose(tick, datacenterID, machineID, sequence int64) int64 {
+	return ((tick - l.epochTicks()) << l.timestampShift()) |
+		(datacenterID << l.datacenterShift()) |
+		(machineID << l.machineShift()) |
+		sequence
+}
+
+// Decode 按布局拆解 Snowflake ID
+func (l Layout) Decode(id int64) SnowflakeParts {
+	tick := (id >> l.timestampShift()) + l.epochTicks()
+	parts := SnowflakeParts{
+		Timestamp:    tick * l.unitMillis(),
+		DatacenterID: (id >> l.datacenterShift()) & l.MaxDatacenter(),
+		MachineID:    (id >> l.machineShift()) & l.MaxMachine(),
+		Sequence:     id & l.maxSequence(),
+		Valid:        true,
+	}
+	switch {
+	case id < 0 || tick < l.epochTicks():
+		parts.Valid = false
+		parts.Reason = "timestamp is before epoch"
+	case tick > l.ticks(time.Now().UnixMilli()):
+		parts.Valid = false
+		parts.Reason = "timestamp is in the future"
+	}
+	return parts
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(l *Layout)
+		want   string // 为空表示校验通过
+	}{
+		{"default", func(l *Layout) {}, ""},
+		{"sonyflake style", func(l *Layout) {
+			l.TimeUnit = 10 * time.Millisecond
+			l.TimestampBits, l.DatacenterBits, l.MachineBits, l.SequenceBits = 39, 0, 16, 8
+		}, ""},
+		{"64 bits", func(l *Layout) { l.SequenceBits = 13 }, "uses 64 bits"},
+		{"zero sequence bits", func(l *Layout) { l.SequenceBits = 0 }, "must be positive"},
+		{"negative machine bits", func(l *Layout) { l.MachineBits = -1 }, "must not be negative"},
+		{"sub-millisecond unit", func(l *Layout) { l.TimeUnit = 500 * time.Microsecond }, "multiple of 1ms"},
+		{"fractional unit", func(l *Layout) { l.TimeUnit = 1500 * time.Microsecond }, "multiple of 1ms"},
+		{"epoch in the future", func(l *Layout) { l.Epoch = time.Now().Add(time.Hour) }, "epoch"},
+		{"epoch before 1970", func(l *Layout) { l.Epoch = time.Date(1969, 1, 1, 0, 0, 0, 0, time.UTC) }, "epoch"},
+		{"timestamp bits exhausted", func(l *Layout) { l.TimestampBits = 20 }, "timestamp bits exhausted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := DefaultLayout
+			tt.modify(&l)
+			err := l.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutConfig(t *testing.T) {
+	cfg := defaultConfig().Snowflake.Layout
+	layout, err := cfg.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !layout.Epoch.Equal(DefaultLayout.Epoch) || layout.TimeUnit != DefaultLayout.TimeUnit || layout.SequenceBits != DefaultLayout.SequenceBits {
+		t.Fatalf("default layout config = %+v, want %+v", layout, DefaultLayout)
+	}
+
+	bad := cfg
+	bad.Epoch = "2021-01-01"
+	if _, err := bad.Layout(); err == nil || !strings.Contains(err.Error(), "invalid epoch") {
+		t.Fatalf("non-RFC3339 epoch: %v", err)
+	}
+	bad = cfg
+	bad.TimeUnit = 2500 * time.Microsecond
+	if _, err := bad.Layout(); err == nil {
+		t.Fatal("accepted a time unit that is not a multiple of 1ms")
+	}
+	bad = cfg
+	bad.DatacenterBits, bad.MachineBits = 6, 5
+	if _, err := bad.Layout(); err == nil {
+		t.Fatal("accepted a layout using 64 bits")
+	}
+}
+
+func TestLayoutDecode(t *testing.T) {
+	layout := DefaultLayout
+	now := time.Now().UnixMilli()
+	tests := []struct {
+		name   string
+		id     int64
+		valid  bool
+		reason string
+	}{
+		{"current", layout.compose(layout.ticks(now), 3, 4, 5), true, ""},
+		{"at epoch", layout.compose(layout.epochTicks(), 0, 0, 0), true, ""},
+		{"negative", -1, false, "timestamp is before epoch"},
+		{"in the future", layout.compose(layout.ticks(now+time.Hour.Milliseconds()), 3, 4, 5), false, "timestamp is in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := layout.Decode(tt.id)
+			if parts.Valid != tt.valid || parts.Reason != tt.reason {
+				t.Fatalf("Decode(%d) = valid %v reason %q, want %v %q", tt.id, parts.Valid, parts.Reason, tt.valid, tt.reason)
+			}
+		})
+	}
+
+	// 10ms 时间单位的时间戳按单位取整
+	coarse := DefaultLayout
+	coarse.TimeUnit = 10 * time.Millisecond
+	parts := coarse.Decode(coarse.compose(coarse.ticks(now), 1, 2, 3))
+	if parts.Timestamp != now/10*10 || parts.DatacenterID != 1 || parts.MachineID != 2 || parts.Sequence != 3 {
+		t.Fatalf("10ms layout decoded %+v", parts)
+	}
+}
@@ -11,14 +11,19 @@ import (
 
 var mLog *zap.Logger
 
-func Init() {
+func Init(cfg LogConfig) error {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
 	encoder := getEncoder()
-	writerSyncer := getLogWriter("./logs/mid.log")
-	core := zapcore.NewCore(encoder, writerSyncer, zapcore.DebugLevel)
+	writerSyncer := getLogWriter(cfg.Path)
+	core := zapcore.NewCore(encoder, writerSyncer, level)
 	var allCode []zapcore.Core
 	allCode = append(allCode, core)
 	c := zapcore.NewTee(allCode...)
 	mLog = zap.New(c, zap.AddCaller())
+	return nil
 }
 
 func getEncoder() zapcore.Encoder {
@@ -1,108 +1,313 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/mazezen/mid/proto/pb"
+	pbv2 "github.com/mazezen/mid/proto/pb/v2"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 )
 
 func main() {
-	Init()
-	snowflake, err := NewSnowflake(1, 1)
+	cfg, err := LoadConfig(os.Args[1:])
 	if err != nil {
-		mLog.Error("创建snowfake失败", zap.Error(err))
+		fmt.Fprintln(os.Stderr, "加载配置失败:", err)
+		os.Exit(2)
 	}
+	if err := Init(cfg.Log); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化日志失败:", err)
+		os.Exit(2)
+	}
+	fmt.Print("effective config:\n", cfg)
+	mLog.Info("Effective config", zap.String("config", cfg.String()))
 
 	// 启动 Prometheus 端点
-	go func () {
-		http.Handle("/metrics", promhttp.Handler())
-		mLog.Info("Prometheus metrics server starting on :9190")
-		if err := http.ListenAndServe(":9190", nil); err != nil {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{Addr: cfg.Metrics.Addr, Handler: mux}
+	go func() {
+		mLog.Info("Prometheus metrics server starting", zap.String("addr", cfg.Metrics.Addr))
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			mLog.Error("Failed to start Prometheus server", zap.Error(err))
 		}
 	}()
 
-	// dsn
-	dsn := "root:123456@tcp(localhost:3306)/mid"
-	segment, err := NewSegment(dsn)
+	// MySQL 供 mysql 号段后端和机器 ID 注册表共用
+	var db *sql.DB
+	if cfg.MySQL.DSN != "" {
+		if db, err = openMySQL(cfg.MySQL); err != nil {
+			mLog.Error("连接 MySQL 失败", zap.Error(err))
+		}
+	}
+	alloc, err := openSegmentAllocator(cfg.Segment, db)
 	if err != nil {
-		mLog.Error("创建segment失败", zap.Error(err))
+		mLog.Error("初始化号段存储后端失败", zap.String("backend", cfg.Segment.Backend), zap.Error(err))
 	}
-	segment.StartPreload()
-	defer segment.Close()
-
-	s := &server{
-		snowflake: snowflake,
-		segment: segment,
-		snowfalkeBuffers: &struct{
-			buffer1 *IDBuffer
-            buffer2 *IDBuffer
-            m1     sync.Mutex
-            m2     sync.Mutex
-			}{
-				buffer1: NewIDBuffer(10000, 5000), // Buffer 大小 1000，阈值 50%
-				buffer2: NewIDBuffer(10000, 5000),
-			},
-		segmentBuffers: &struct{
-			buffer1 *IDBuffer
-            buffer2 *IDBuffer
-            m1     sync.Mutex
-            m2     sync.Mutex
-			}{
-				buffer1: NewIDBuffer(10000, 5000), // Buffer 大小 1000，阈值 50%
-				buffer2: NewIDBuffer(10000, 5000),
-			 },
+	if alloc == nil {
+		mLog.Warn("号段存储后端不可用，segment 模式不可用", zap.String("backend", cfg.Segment.Backend))
 	}
 
-	// 顺序填充 Buffer，避免并发竞争
-	if err := s.fillBuffer(s.snowfalkeBuffers.buffer1, "snowflake"); err != nil {
-		mLog.Error("初始化补充 snowflake buffer1 失败", zap.Error(err))
+	// 配置已校验过位布局
+	layout, _ := cfg.Snowflake.Layout.Layout()
+
+	// 机器 ID 来自注册表时，抢占失败无法保证 ID 唯一，直接退出
+	var registry *WorkerRegistry
+	var snowflake *Snowflake
+	if cfg.Snowflake.WorkerRegistry {
+		if db == nil {
+			mLog.Fatal("worker registry requires MySQL")
+		}
+		if registry, err = NewWorkerRegistry(db, cfg.Snowflake.DatacenterID, layout.MaxMachine(), cfg.Snowflake.LeaseTTL); err != nil {
+			mLog.Fatal("租用机器 ID 失败", zap.Error(err))
+		}
+		snowflake, err = NewSnowflakeWithRegistry(registry, layout)
+	} else {
+		snowflake, err = NewSnowflake(cfg.Snowflake.DatacenterID, cfg.Snowflake.MachineID, layout)
 	}
-	if err := s.fillBuffer(s.snowfalkeBuffers.buffer2, "snowflake"); err != nil {
-		mLog.Error("初始化补充 snowflake buffer2 失败", zap.Error(err))
+	if err != nil {
+		mLog.Fatal("创建 snowflake 失败", zap.Error(err))
 	}
 
-	if err := s.fillBuffer(s.segmentBuffers.buffer1, "segment"); err != nil {
-		mLog.Error("初始化补充 segment buffer1 失败", zap.Error(err))
+	// 恢复时间戳高水位，等待时钟越过重启前发出的最大时间戳
+	var stores []timestampStore
+	if cfg.Snowflake.WatermarkFile != "" {
+		stores = append(stores, &fileTimestampStore{path: cfg.Snowflake.WatermarkFile})
+	}
+	if registry != nil {
+		stores = append(stores, registry)
 	}
-	if err := s.fillBuffer(s.segmentBuffers.buffer2, "segment"); err != nil {
-		mLog.Error("初始化补充 segment buffer2 失败", zap.Error(err))
+	var mark *watermark
+	if len(stores) > 0 {
+		mark = newWatermark(snowflake, cfg.Snowflake.WatermarkInterval, stores...)
+		if err := mark.Restore(cfg.Snowflake.StartupWait); err != nil {
+			mLog.Fatal("恢复 snowflake 时间戳高水位失败", zap.Error(err))
+		}
+		mark.Start()
+	}
+
+	s := newServer(snowflake, alloc, cfg.Segment, cfg.Buffer)
+
+	// 预热默认业务标识，其余业务标识在首次请求时创建
+	if alloc != nil {
+		if _, err := s.segmentBuffer(defaultBizTag); err != nil {
+			mLog.Error("初始化默认 segment 失败", zap.Error(err))
+		}
 	}
 
 	// 配置 gRPC 服务端 KeepAlive 参数
+	ka := cfg.GRPC.Keepalive
 	serverOptions := []grpc.ServerOption{
-		grpc.MaxConcurrentStreams(10000), // 最大并发限流
+		grpc.MaxConcurrentStreams(cfg.GRPC.MaxConcurrentStreams), // 最大并发限流
 		grpc.KeepaliveParams(keepalive.ServerParameters{
-			MaxConnectionIdle:     15 * time.Second, // 最大空闲时间
-			MaxConnectionAge:      30 * time.Second, // 最大连接存活时间
-			MaxConnectionAgeGrace: 5 * time.Second,  // 优雅关闭的宽限期
-			Time:                  5 * time.Second,  // 发送 ping 的间隔
-			Timeout:               5 * time.Second,  // 等待 ping 响应的超时
+			MaxConnectionIdle:     ka.MaxConnectionIdle,     // 最大空闲时间
+			MaxConnectionAge:      ka.MaxConnectionAge,      // 最大连接存活时间
+			MaxConnectionAgeGrace: ka.MaxConnectionAgeGrace, // 优雅关闭的宽限期
+			Time:                  ka.Time,                  // 发送 ping 的间隔
+			Timeout:               ka.Timeout,               // 等待 ping 响应的超时
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
-			MinTime:             5 * time.Second, // 客户端 ping 的最小间隔
-			PermitWithoutStream: true,            // 允许无活跃流时发送 ping
+			MinTime:             ka.MinTime,             // 客户端 ping 的最小间隔
+			PermitWithoutStream: ka.PermitWithoutStream, // 允许无活跃流时发送 ping
 		}),
 	}
 	grpcServer := grpc.NewServer(serverOptions...)
 	pb.RegisterIDMakerServer(grpcServer, s)
+	pbv2.RegisterIDGeneratorServer(grpcServer, newServerV2(s))
+
+	// 健康检查：grpc.health.v1 和指标端口上的 /healthz
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	checker := newHealthChecker(s, healthServer, cfg.Health.CheckInterval)
+	checker.Start()
+	mux.Handle("/healthz", checker)
+
+	// HTTP/JSON 网关，未单独配置地址时与指标服务共用端口
+	var (
+		gw            *gateway
+		gatewayServer *http.Server
+	)
+	if cfg.Gateway.Enabled {
+		gw = newGateway(s)
+		if cfg.Gateway.Addr == "" {
+			gw.register(mux)
+		} else {
+			gatewayMux := http.NewServeMux()
+			gw.register(gatewayMux)
+			gatewayServer = &http.Server{Addr: cfg.Gateway.Addr, Handler: gatewayMux}
+			go func() {
+				mLog.Info("HTTP gateway starting", zap.String("addr", cfg.Gateway.Addr))
+				if err := gatewayServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					mLog.Error("Failed to start HTTP gateway", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	// 兼容 Redis 协议的监听器，便于使用 INCR 的调用方只修改连接地址即可迁移
+	var redisServer *respServer
+	if cfg.Redis.Addr != "" {
+		redisServer = newRESPServer(s)
+		go func() {
+			mLog.Info("Redis protocol listener starting", zap.String("addr", cfg.Redis.Addr))
+			if err := redisServer.ListenAndServe(cfg.Redis.Addr); err != nil && !errors.Is(err, net.ErrClosed) {
+				mLog.Error("Failed to start Redis protocol listener", zap.Error(err))
+			}
+		}()
+	}
 
-	lis, err := net.Listen("tcp", ":50051")
-	fmt.Println("grpc server listen:", ":50051")
+	lis, err := net.Listen("tcp", cfg.GRPC.Addr)
+	fmt.Println("grpc server listen:", cfg.GRPC.Addr)
 	if err != nil {
-		mLog.Error("创建 gRPC 服务 失败", zap.Error(err))
+		mLog.Fatal("创建 gRPC 服务 失败", zap.Error(err))
 	}
-	
-	mLog.Info("gRPC server running on :50051")
-	if err := grpcServer.Serve(lis); err != nil {
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		mLog.Info("gRPC server running", zap.String("addr", cfg.GRPC.Addr))
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	select {
+	case <-ctx.Done():
+		mLog.Info("Received shutdown signal")
+	case err := <-serveErr:
 		mLog.Error("创建 gRPC 服务 失败", zap.Error(err))
 	}
-}
\ No newline at end of file
+	gracefulShutdown(cfg.ShutdownTimeout, resources{
+		grpcServer:    grpcServer,
+		metricsServer: metricsServer,
+		gateway:       gw,
+		gatewayServer: gatewayServer,
+		redisServer:   redisServer,
+		health:        checker,
+		server:        s,
+		watermark:     mark,
+		registry:      registry,
+		alloc:         alloc,
+		db:            db,
+	})
+}
+
+// resources 关闭时需要释放的资源，未启用的为 nil
+type resources struct {
+	grpcServer    *grpc.Server
+	metricsServer *http.Server
+	gateway       *gateway
+	gatewayServer *http.Server // 网关单独监听时的 HTTP 服务
+	redisServer   *respServer
+	health        *healthChecker
+	server        *server
+	watermark     *watermark
+	registry      *WorkerRegistry
+	alloc         segmentAllocator
+	db            *sql.DB
+}
+
+// gracefulShutdown 停止接收请求，按依赖顺序释放资源。每个阶段最多等待 timeout，
+// 前一阶段超时不会让后续阶段拿到已过期的 ctx
+func gracefulShutdown(timeout time.Duration, r resources) {
+	defer mLog.Sync()
+	phase := func(fn func(ctx context.Context)) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		fn(ctx)
+	}
+
+	// 先标记为 NOT_SERVING，让负载均衡停止转发新请求
+	r.health.Shutdown()
+	// 结束进行中的 StreamIDs，否则 GracefulStop 要等到客户端主动取消流
+	r.server.Drain()
+
+	// 停止接收新的 RPC，等待进行中的请求完成，超时后强制关闭
+	phase(func(ctx context.Context) {
+		stopped := make(chan struct{})
+		go func() {
+			r.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			mLog.Info("gRPC server stopped")
+		case <-ctx.Done():
+			mLog.Warn("gRPC graceful stop timed out, forcing stop")
+			r.grpcServer.Stop()
+		}
+	})
+
+	// 网关与指标服务共用端口时，指标服务要到最后才关闭，先单独停止网关的路由，避免排空 Buffer 时仍在发号
+	if r.gateway != nil {
+		phase(func(ctx context.Context) {
+			err := r.gateway.Shutdown(ctx)
+			if r.gatewayServer != nil {
+				err = errors.Join(err, r.gatewayServer.Shutdown(ctx))
+			}
+			if err != nil {
+				mLog.Warn("Failed to shut down HTTP gateway", zap.Error(err))
+			}
+		})
+	}
+
+	if r.redisServer != nil {
+		phase(func(ctx context.Context) {
+			if err := r.redisServer.Shutdown(ctx); err != nil {
+				mLog.Warn("Failed to shut down Redis protocol listener", zap.Error(err))
+			}
+		})
+	}
+
+	// 等待进行中的 Buffer 填充，避免在号段存储后端关闭后仍在取号段
+	phase(func(ctx context.Context) {
+		if err := r.server.Shutdown(ctx); err != nil {
+			mLog.Warn("Buffer fills did not finish before shutdown deadline", zap.Error(err))
+		}
+	})
+
+	if r.metricsServer != nil {
+		phase(func(ctx context.Context) {
+			if err := r.metricsServer.Shutdown(ctx); err != nil {
+				mLog.Warn("Failed to shut down Prometheus server", zap.Error(err))
+			}
+		})
+	}
+
+	// 保存精确的时间戳高水位，需在释放机器 ID 之前完成
+	if r.watermark != nil {
+		r.watermark.Close()
+	}
+
+	// 释放机器 ID，其他节点无需等待租约过期即可复用
+	if r.registry != nil {
+		if err := r.registry.Close(); err != nil {
+			mLog.Warn("Failed to release worker id", zap.Error(err))
+		}
+	}
+
+	if r.alloc != nil {
+		if err := r.alloc.Close(); err != nil {
+			mLog.Warn("Failed to close segment backend", zap.Error(err))
+		}
+	}
+	if r.db != nil {
+		if err := r.db.Close(); err != nil {
+			mLog.Warn("Failed to close MySQL", zap.Error(err))
+		}
+	}
+	mLog.Info("Shutdown complete")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// errInjectedFailure memoryAllocator 注入的故障
+var errInjectedFailure = errors.New("injected segment backend failure")
+
+// memoryAllocator 进程内的号段存储后端，语义与 id_segments 表一致：按业务标识原子地分配不重叠的号段。
+// 数据不持久化，重启后从 0 开始，仅用于测试、压测和本地演示；支持注入延迟和故障模拟存储后端异常
+type memoryAllocator struct {
+	mu       sync.Mutex
+	segments map[string]*memorySegment
+
+	latency     time.Duration // 每次 Reserve 的额外延迟
+	failureRate float64       // Reserve 随机失败的概率
+	failNext    int           // 接下来固定失败的 Reserve 次数
+	failErr     error
+	rand        *rand.Rand
+}
+
+type memorySegment struct {
+	maxID int64
+	step  int64
+}
+
+// newMemoryAllocator 创建内存后端，steps 为初始的业务标识及其基础步长
+func newMemoryAllocator(steps map[string]int64) *memoryAllocator {
+	m := &memoryAllocator{
+		segments: make(map[string]*memorySegment, len(steps)),
+		failErr:  errInjectedFailure,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for bizTag, step := range steps {
+		m.segments[bizTag] = &memorySegment{step: step}
+	}
+	return m
+}
+
+// AddBizTag 添加业务标识，相当于在 id_segments 表中插入一行
+func (m *memoryAllocator) AddBizTag(bizTag string, maxID, step int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.segments[bizTag] = &memorySegment{maxID: maxID, step: step}
+}
+
+// SetLatency 设置每次 Reserve 的额外延迟
+func (m *memoryAllocator) SetLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = d
+}
+
+// SetFailureRate 设置 Reserve 随机失败的概率，0 表示不失败
+func (m *memoryAllocator) SetFailureRate(rate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failureRate = rate
+}
+
+// FailNext 让接下来的 n 次 Reserve 返回 err，err 为 nil 时返回 errInjectedFailure
+func (m *memoryAllocator) FailNext(n int, err error) {
+	if err == nil {
+		err = errInjectedFailure
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failNext = n
+	m.failErr = err
+}
+
+// MaxID 返回 bizTag 当前的 max_id
+func (m *memoryAllocator) MaxID(bizTag string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if seg, ok := m.segments[bizTag]; ok {
+		return seg.maxID
+	}
+	return 0
+}
+
+func (m *memoryAllocator) Backend() string {
+	return backendMemory
+}
+
+func (m *memoryAllocator) Step(ctx context.Context, bizTag string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seg, ok := m.segments[bizTag]
+	if !ok {
+		return 0, errBizTagNotFound
+	}
+	return seg.step, nil
+}
+
+func (m *memoryAllocator) Reserve(ctx context.Context, bizTag string, step int64) (int64, error) {
+	m.mu.Lock()
+	latency := m.latency
+	var injected error
+	switch {
+	case m.failNext > 0:
+		m.failNext--
+		injected = m.failErr
+	case m.failureRate > 0 && m.rand.Float64() < m.failureRate:
+		injected = errInjectedFailure
+	}
+	m.mu.Unlock()
+
+	// 延迟在锁外等待，模拟并发请求同时等待数据库的情况
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if injected != nil {
+		return 0, injected
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seg, ok := m.segments[bizTag]
+	if !ok {
+		return 0, errBizTagNotFound
+	}
+	seg.maxID += step
+	return seg.maxID, nil
+}
+
+func (m *memoryAllocator) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryAllocator) Close() error {
+	return nil
+}
@@ -23,7 +23,8 @@ const (
 
 type MakeIDServiceRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"` // ID 生成模式："snowflake" 或 "segment"
+	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`                   // ID 生成模式："snowflake" 或 "segment"
+	BizTag        string                 `protobuf:"bytes,2,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"` // 业务标识，仅 segment 模式使用，为空时使用 "default"
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +66,13 @@ func (x *MakeIDServiceRequest) GetMode() string {
 	return ""
 }
 
+func (x *MakeIDServiceRequest) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
 type MakeIDServiceResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -109,17 +117,389 @@ func (x *MakeIDServiceResponse) GetId() int64 {
 	return 0
 }
 
+type MakeIDBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`                   // ID 生成模式："snowflake" 或 "segment"
+	BizTag        string                 `protobuf:"bytes,2,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"` // 业务标识，仅 segment 模式使用，为空时使用 "default"
+	Count         int32                  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`                // 需要的 ID 数量，不能超过服务端上限
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MakeIDBatchRequest) Reset() {
+	*x = MakeIDBatchRequest{}
+	mi := &file_id_maker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MakeIDBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MakeIDBatchRequest) ProtoMessage() {}
+
+func (x *MakeIDBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MakeIDBatchRequest.ProtoReflect.Descriptor instead.
+func (*MakeIDBatchRequest) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MakeIDBatchRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *MakeIDBatchRequest) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
+func (x *MakeIDBatchRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type MakeIDBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ids           []int64                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"` // segment 模式下严格递增
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MakeIDBatchResponse) Reset() {
+	*x = MakeIDBatchResponse{}
+	mi := &file_id_maker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MakeIDBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MakeIDBatchResponse) ProtoMessage() {}
+
+func (x *MakeIDBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MakeIDBatchResponse.ProtoReflect.Descriptor instead.
+func (*MakeIDBatchResponse) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MakeIDBatchResponse) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type StreamIDsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`                                        // ID 生成模式："snowflake" 或 "segment"
+	BizTag        string                 `protobuf:"bytes,2,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"`                      // 业务标识，仅 segment 模式使用，为空时使用 "default"
+	ChunkSize     int32                  `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`            // 每次推送的 ID 数量，不能超过服务端上限
+	IdsPerSecond  int32                  `protobuf:"varint,4,opt,name=ids_per_second,json=idsPerSecond,proto3" json:"ids_per_second,omitempty"` // 可选，推送速率上限，0 表示不限速
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamIDsRequest) Reset() {
+	*x = StreamIDsRequest{}
+	mi := &file_id_maker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamIDsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamIDsRequest) ProtoMessage() {}
+
+func (x *StreamIDsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamIDsRequest.ProtoReflect.Descriptor instead.
+func (*StreamIDsRequest) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamIDsRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *StreamIDsRequest) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
+func (x *StreamIDsRequest) GetChunkSize() int32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+func (x *StreamIDsRequest) GetIdsPerSecond() int32 {
+	if x != nil {
+		return x.IdsPerSecond
+	}
+	return 0
+}
+
+type IDChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ids           []int64                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IDChunk) Reset() {
+	*x = IDChunk{}
+	mi := &file_id_maker_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IDChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IDChunk) ProtoMessage() {}
+
+func (x *IDChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IDChunk.ProtoReflect.Descriptor instead.
+func (*IDChunk) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IDChunk) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type DecodeIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"` // Snowflake ID
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeIDRequest) Reset() {
+	*x = DecodeIDRequest{}
+	mi := &file_id_maker_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeIDRequest) ProtoMessage() {}
+
+func (x *DecodeIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeIDRequest.ProtoReflect.Descriptor instead.
+func (*DecodeIDRequest) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DecodeIDRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type DecodeIDResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // 生成时间，Unix 毫秒
+	DatacenterId  int64                  `protobuf:"varint,2,opt,name=datacenter_id,json=datacenterId,proto3" json:"datacenter_id,omitempty"`
+	MachineId     int64                  `protobuf:"varint,3,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Sequence      int64                  `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Valid         bool                   `protobuf:"varint,5,opt,name=valid,proto3" json:"valid,omitempty"`  // 时间戳不早于 epoch 且不晚于当前时间
+	Reason        string                 `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"` // valid 为 false 时的原因
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeIDResponse) Reset() {
+	*x = DecodeIDResponse{}
+	mi := &file_id_maker_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeIDResponse) ProtoMessage() {}
+
+func (x *DecodeIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_id_maker_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeIDResponse.ProtoReflect.Descriptor instead.
+func (*DecodeIDResponse) Descriptor() ([]byte, []int) {
+	return file_id_maker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DecodeIDResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *DecodeIDResponse) GetDatacenterId() int64 {
+	if x != nil {
+		return x.DatacenterId
+	}
+	return 0
+}
+
+func (x *DecodeIDResponse) GetMachineId() int64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+func (x *DecodeIDResponse) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *DecodeIDResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *DecodeIDResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 var File_id_maker_proto protoreflect.FileDescriptor
 
 const file_id_maker_proto_rawDesc = "" +
 	"\n" +
-	"\x0eid_maker.proto\x12\x02pb\"*\n" +
+	"\x0eid_maker.proto\x12\x02pb\"C\n" +
 	"\x14MakeIDServiceRequest\x12\x12\n" +
-	"\x04mode\x18\x01 \x01(\tR\x04mode\"'\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x17\n" +
+	"\abiz_tag\x18\x02 \x01(\tR\x06bizTag\"'\n" +
 	"\x15MakeIDServiceResponse\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x03R\x02id2O\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"W\n" +
+	"\x12MakeIDBatchRequest\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x17\n" +
+	"\abiz_tag\x18\x02 \x01(\tR\x06bizTag\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\"'\n" +
+	"\x13MakeIDBatchResponse\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x03R\x03ids\"\x84\x01\n" +
+	"\x10StreamIDsRequest\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x17\n" +
+	"\abiz_tag\x18\x02 \x01(\tR\x06bizTag\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x03 \x01(\x05R\tchunkSize\x12$\n" +
+	"\x0eids_per_second\x18\x04 \x01(\x05R\fidsPerSecond\"\x1b\n" +
+	"\aIDChunk\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x03R\x03ids\"!\n" +
+	"\x0fDecodeIDRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xbe\x01\n" +
+	"\x10DecodeIDResponse\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12#\n" +
+	"\rdatacenter_id\x18\x02 \x01(\x03R\fdatacenterId\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x03 \x01(\x03R\tmachineId\x12\x1a\n" +
+	"\bsequence\x18\x04 \x01(\x03R\bsequence\x12\x14\n" +
+	"\x05valid\x18\x05 \x01(\bR\x05valid\x12\x16\n" +
+	"\x06reason\x18\x06 \x01(\tR\x06reason2\xf8\x01\n" +
 	"\aIDMaker\x12D\n" +
-	"\rMakeIDService\x12\x18.pb.MakeIDServiceRequest\x1a\x19.pb.MakeIDServiceResponseB\x06Z\x04./pbb\x06proto3"
+	"\rMakeIDService\x12\x18.pb.MakeIDServiceRequest\x1a\x19.pb.MakeIDServiceResponse\x12>\n" +
+	"\vMakeIDBatch\x12\x16.pb.MakeIDBatchRequest\x1a\x17.pb.MakeIDBatchResponse\x120\n" +
+	"\tStreamIDs\x12\x14.pb.StreamIDsRequest\x1a\v.pb.IDChunk0\x01\x125\n" +
+	"\bDecodeID\x12\x13.pb.DecodeIDRequest\x1a\x14.pb.DecodeIDResponseB\x06Z\x04./pbb\x06proto3"
 
 var (
 	file_id_maker_proto_rawDescOnce sync.Once
@@ -133,16 +513,28 @@ func file_id_maker_proto_rawDescGZIP() []byte {
 	return file_id_maker_proto_rawDescData
 }
 
-var file_id_maker_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_id_maker_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_id_maker_proto_goTypes = []any{
 	(*MakeIDServiceRequest)(nil),  // 0: pb.MakeIDServiceRequest
 	(*MakeIDServiceResponse)(nil), // 1: pb.MakeIDServiceResponse
+	(*MakeIDBatchRequest)(nil),    // 2: pb.MakeIDBatchRequest
+	(*MakeIDBatchResponse)(nil),   // 3: pb.MakeIDBatchResponse
+	(*StreamIDsRequest)(nil),      // 4: pb.StreamIDsRequest
+	(*IDChunk)(nil),               // 5: pb.IDChunk
+	(*DecodeIDRequest)(nil),       // 6: pb.DecodeIDRequest
+	(*DecodeIDResponse)(nil),      // 7: pb.DecodeIDResponse
 }
 var file_id_maker_proto_depIdxs = []int32{
 	0, // 0: pb.IDMaker.MakeIDService:input_type -> pb.MakeIDServiceRequest
-	1, // 1: pb.IDMaker.MakeIDService:output_type -> pb.MakeIDServiceResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: pb.IDMaker.MakeIDBatch:input_type -> pb.MakeIDBatchRequest
+	4, // 2: pb.IDMaker.StreamIDs:input_type -> pb.StreamIDsRequest
+	6, // 3: pb.IDMaker.DecodeID:input_type -> pb.DecodeIDRequest
+	1, // 4: pb.IDMaker.MakeIDService:output_type -> pb.MakeIDServiceResponse
+	3, // 5: pb.IDMaker.MakeIDBatch:output_type -> pb.MakeIDBatchResponse
+	5, // 6: pb.IDMaker.StreamIDs:output_type -> pb.IDChunk
+	7, // 7: pb.IDMaker.DecodeID:output_type -> pb.DecodeIDResponse
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -159,7 +551,7 @@ func file_id_maker_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_id_maker_proto_rawDesc), len(file_id_maker_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
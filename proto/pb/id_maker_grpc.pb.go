@@ -20,13 +20,45 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	IDMaker_MakeIDService_FullMethodName = "/pb.IDMaker/MakeIDService"
+	IDMaker_MakeIDBatch_FullMethodName   = "/pb.IDMaker/MakeIDBatch"
+	IDMaker_StreamIDs_FullMethodName     = "/pb.IDMaker/StreamIDs"
+	IDMaker_DecodeID_FullMethodName      = "/pb.IDMaker/DecodeID"
 )
 
 // IDMakerClient is the client API for IDMaker service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IDMaker 生成 ID 的服务。
+//
+// 错误使用标准 gRPC 状态码，并在 details 中附带 google.rpc.ErrorInfo（domain 为 "mid"，
+// reason 见下表，metadata 中有 mode 和 biz_tag）；建议等待后重试的错误还附带 google.rpc.RetryInfo。
+// 参数错误附带 google.rpc.BadRequest，指出出错的字段。
+//
+// 可重试（生成 ID 是幂等的，重试只会浪费 ID 而不会重复，建议换其他节点重试）：
+//
+//	UNAVAILABLE         CLOCK_ROLLBACK       时钟回退超过允许的漂移范围或高水位未能持久化，附带 RetryInfo
+//	                    LEASE_LOST           机器 ID 租约失效，附带 RetryInfo
+//	                    BACKEND_UNAVAILABLE  号段存储后端出错（数据库不可达、Raft 集群没有 leader 等），附带 RetryInfo
+//	                    SHUTTING_DOWN        节点正在关闭，应立即换其他节点
+//	RESOURCE_EXHAUSTED  BUFFER_EMPTY         等待 Buffer 填充超时，附带 RetryInfo
+//	DEADLINE_EXCEEDED                        请求超时
+//
+// 不可重试（重试仍会得到相同的错误）：
+//
+//	INVALID_ARGUMENT                         mode、count、chunk_size 等参数错误，附带 BadRequest
+//	NOT_FOUND           BIZ_TAG_NOT_FOUND    业务标识不存在
+//	FAILED_PRECONDITION SEGMENT_DISABLED     节点未配置号段存储后端
+//	                    INVALID_STEP         业务标识的步长配置错误
+//	                    TIMESTAMP_OVERFLOW   时间戳位数用尽，需要调整 epoch 或位布局
+//	INTERNAL            INTERNAL             其他内部错误
 type IDMakerClient interface {
 	MakeIDService(ctx context.Context, in *MakeIDServiceRequest, opts ...grpc.CallOption) (*MakeIDServiceResponse, error)
+	MakeIDBatch(ctx context.Context, in *MakeIDBatchRequest, opts ...grpc.CallOption) (*MakeIDBatchResponse, error)
+	// StreamIDs 持续推送 ID，直到客户端取消
+	StreamIDs(ctx context.Context, in *StreamIDsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IDChunk], error)
+	// DecodeID 拆解 Snowflake ID，便于排查生成时间和节点
+	DecodeID(ctx context.Context, in *DecodeIDRequest, opts ...grpc.CallOption) (*DecodeIDResponse, error)
 }
 
 type iDMakerClient struct {
@@ -47,11 +79,79 @@ func (c *iDMakerClient) MakeIDService(ctx context.Context, in *MakeIDServiceRequ
 	return out, nil
 }
 
+func (c *iDMakerClient) MakeIDBatch(ctx context.Context, in *MakeIDBatchRequest, opts ...grpc.CallOption) (*MakeIDBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MakeIDBatchResponse)
+	err := c.cc.Invoke(ctx, IDMaker_MakeIDBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iDMakerClient) StreamIDs(ctx context.Context, in *StreamIDsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IDChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IDMaker_ServiceDesc.Streams[0], IDMaker_StreamIDs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamIDsRequest, IDChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IDMaker_StreamIDsClient = grpc.ServerStreamingClient[IDChunk]
+
+func (c *iDMakerClient) DecodeID(ctx context.Context, in *DecodeIDRequest, opts ...grpc.CallOption) (*DecodeIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DecodeIDResponse)
+	err := c.cc.Invoke(ctx, IDMaker_DecodeID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IDMakerServer is the server API for IDMaker service.
 // All implementations must embed UnimplementedIDMakerServer
 // for forward compatibility.
+//
+// IDMaker 生成 ID 的服务。
+//
+// 错误使用标准 gRPC 状态码，并在 details 中附带 google.rpc.ErrorInfo（domain 为 "mid"，
+// reason 见下表，metadata 中有 mode 和 biz_tag）；建议等待后重试的错误还附带 google.rpc.RetryInfo。
+// 参数错误附带 google.rpc.BadRequest，指出出错的字段。
+//
+// 可重试（生成 ID 是幂等的，重试只会浪费 ID 而不会重复，建议换其他节点重试）：
+//
+//	UNAVAILABLE         CLOCK_ROLLBACK       时钟回退超过允许的漂移范围或高水位未能持久化，附带 RetryInfo
+//	                    LEASE_LOST           机器 ID 租约失效，附带 RetryInfo
+//	                    BACKEND_UNAVAILABLE  号段存储后端出错（数据库不可达、Raft 集群没有 leader 等），附带 RetryInfo
+//	                    SHUTTING_DOWN        节点正在关闭，应立即换其他节点
+//	RESOURCE_EXHAUSTED  BUFFER_EMPTY         等待 Buffer 填充超时，附带 RetryInfo
+//	DEADLINE_EXCEEDED                        请求超时
+//
+// 不可重试（重试仍会得到相同的错误）：
+//
+//	INVALID_ARGUMENT                         mode、count、chunk_size 等参数错误，附带 BadRequest
+//	NOT_FOUND           BIZ_TAG_NOT_FOUND    业务标识不存在
+//	FAILED_PRECONDITION SEGMENT_DISABLED     节点未配置号段存储后端
+//	                    INVALID_STEP         业务标识的步长配置错误
+//	                    TIMESTAMP_OVERFLOW   时间戳位数用尽，需要调整 epoch 或位布局
+//	INTERNAL            INTERNAL             其他内部错误
 type IDMakerServer interface {
 	MakeIDService(context.Context, *MakeIDServiceRequest) (*MakeIDServiceResponse, error)
+	MakeIDBatch(context.Context, *MakeIDBatchRequest) (*MakeIDBatchResponse, error)
+	// StreamIDs 持续推送 ID，直到客户端取消
+	StreamIDs(*StreamIDsRequest, grpc.ServerStreamingServer[IDChunk]) error
+	// DecodeID 拆解 Snowflake ID，便于排查生成时间和节点
+	DecodeID(context.Context, *DecodeIDRequest) (*DecodeIDResponse, error)
 	mustEmbedUnimplementedIDMakerServer()
 }
 
@@ -65,6 +165,15 @@ type UnimplementedIDMakerServer struct{}
 func (UnimplementedIDMakerServer) MakeIDService(context.Context, *MakeIDServiceRequest) (*MakeIDServiceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MakeIDService not implemented")
 }
+func (UnimplementedIDMakerServer) MakeIDBatch(context.Context, *MakeIDBatchRequest) (*MakeIDBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MakeIDBatch not implemented")
+}
+func (UnimplementedIDMakerServer) StreamIDs(*StreamIDsRequest, grpc.ServerStreamingServer[IDChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamIDs not implemented")
+}
+func (UnimplementedIDMakerServer) DecodeID(context.Context, *DecodeIDRequest) (*DecodeIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecodeID not implemented")
+}
 func (UnimplementedIDMakerServer) mustEmbedUnimplementedIDMakerServer() {}
 func (UnimplementedIDMakerServer) testEmbeddedByValue()                 {}
 
@@ -104,6 +213,53 @@ func _IDMaker_MakeIDService_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _IDMaker_MakeIDBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MakeIDBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IDMakerServer).MakeIDBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IDMaker_MakeIDBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IDMakerServer).MakeIDBatch(ctx, req.(*MakeIDBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IDMaker_StreamIDs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamIDsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IDMakerServer).StreamIDs(m, &grpc.GenericServerStream[StreamIDsRequest, IDChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IDMaker_StreamIDsServer = grpc.ServerStreamingServer[IDChunk]
+
+func _IDMaker_DecodeID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IDMakerServer).DecodeID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IDMaker_DecodeID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IDMakerServer).DecodeID(ctx, req.(*DecodeIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // IDMaker_ServiceDesc is the grpc.ServiceDesc for IDMaker service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +271,21 @@ var IDMaker_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "MakeIDService",
 			Handler:    _IDMaker_MakeIDService_Handler,
 		},
+		{
+			MethodName: "MakeIDBatch",
+			Handler:    _IDMaker_MakeIDBatch_Handler,
+		},
+		{
+			MethodName: "DecodeID",
+			Handler:    _IDMaker_DecodeID_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIDs",
+			Handler:       _IDMaker_StreamIDs_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "id_maker.proto",
 }
@@ -0,0 +1,182 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: segment_raft.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ReserveSegmentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BizTag        string                 `protobuf:"bytes,1,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"`
+	Step          int64                  `protobuf:"varint,2,opt,name=step,proto3" json:"step,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReserveSegmentRequest) Reset() {
+	*x = ReserveSegmentRequest{}
+	mi := &file_segment_raft_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReserveSegmentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReserveSegmentRequest) ProtoMessage() {}
+
+func (x *ReserveSegmentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_segment_raft_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReserveSegmentRequest.ProtoReflect.Descriptor instead.
+func (*ReserveSegmentRequest) Descriptor() ([]byte, []int) {
+	return file_segment_raft_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ReserveSegmentRequest) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
+func (x *ReserveSegmentRequest) GetStep() int64 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+type ReserveSegmentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MaxId         int64                  `protobuf:"varint,1,opt,name=max_id,json=maxId,proto3" json:"max_id,omitempty"` // 分配的号段为 (max_id - step, max_id]
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReserveSegmentResponse) Reset() {
+	*x = ReserveSegmentResponse{}
+	mi := &file_segment_raft_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReserveSegmentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReserveSegmentResponse) ProtoMessage() {}
+
+func (x *ReserveSegmentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_segment_raft_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReserveSegmentResponse.ProtoReflect.Descriptor instead.
+func (*ReserveSegmentResponse) Descriptor() ([]byte, []int) {
+	return file_segment_raft_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ReserveSegmentResponse) GetMaxId() int64 {
+	if x != nil {
+		return x.MaxId
+	}
+	return 0
+}
+
+var File_segment_raft_proto protoreflect.FileDescriptor
+
+const file_segment_raft_proto_rawDesc = "" +
+	"\n" +
+	"\x12segment_raft.proto\x12\x02pb\"D\n" +
+	"\x15ReserveSegmentRequest\x12\x17\n" +
+	"\abiz_tag\x18\x01 \x01(\tR\x06bizTag\x12\x12\n" +
+	"\x04step\x18\x02 \x01(\x03R\x04step\"/\n" +
+	"\x16ReserveSegmentResponse\x12\x15\n" +
+	"\x06max_id\x18\x01 \x01(\x03R\x05maxId2V\n" +
+	"\vSegmentRaft\x12G\n" +
+	"\x0eReserveSegment\x12\x19.pb.ReserveSegmentRequest\x1a\x1a.pb.ReserveSegmentResponseB\x06Z\x04./pbb\x06proto3"
+
+var (
+	file_segment_raft_proto_rawDescOnce sync.Once
+	file_segment_raft_proto_rawDescData []byte
+)
+
+func file_segment_raft_proto_rawDescGZIP() []byte {
+	file_segment_raft_proto_rawDescOnce.Do(func() {
+		file_segment_raft_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_segment_raft_proto_rawDesc), len(file_segment_raft_proto_rawDesc)))
+	})
+	return file_segment_raft_proto_rawDescData
+}
+
+var file_segment_raft_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_segment_raft_proto_goTypes = []any{
+	(*ReserveSegmentRequest)(nil),  // 0: pb.ReserveSegmentRequest
+	(*ReserveSegmentResponse)(nil), // 1: pb.ReserveSegmentResponse
+}
+var file_segment_raft_proto_depIdxs = []int32{
+	0, // 0: pb.SegmentRaft.ReserveSegment:input_type -> pb.ReserveSegmentRequest
+	1, // 1: pb.SegmentRaft.ReserveSegment:output_type -> pb.ReserveSegmentResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_segment_raft_proto_init() }
+func file_segment_raft_proto_init() {
+	if File_segment_raft_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_segment_raft_proto_rawDesc), len(file_segment_raft_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_segment_raft_proto_goTypes,
+		DependencyIndexes: file_segment_raft_proto_depIdxs,
+		MessageInfos:      file_segment_raft_proto_msgTypes,
+	}.Build()
+	File_segment_raft_proto = out.File
+	file_segment_raft_proto_goTypes = nil
+	file_segment_raft_proto_depIdxs = nil
+}
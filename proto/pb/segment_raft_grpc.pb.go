@@ -0,0 +1,123 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: segment_raft.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SegmentRaft_ReserveSegment_FullMethodName = "/pb.SegmentRaft/ReserveSegment"
+)
+
+// SegmentRaftClient is the client API for SegmentRaft service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SegmentRaftClient interface {
+	// ReserveSegment follower 将号段分配转发给 leader，由 leader 写入 Raft 日志
+	ReserveSegment(ctx context.Context, in *ReserveSegmentRequest, opts ...grpc.CallOption) (*ReserveSegmentResponse, error)
+}
+
+type segmentRaftClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSegmentRaftClient(cc grpc.ClientConnInterface) SegmentRaftClient {
+	return &segmentRaftClient{cc}
+}
+
+func (c *segmentRaftClient) ReserveSegment(ctx context.Context, in *ReserveSegmentRequest, opts ...grpc.CallOption) (*ReserveSegmentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReserveSegmentResponse)
+	err := c.cc.Invoke(ctx, SegmentRaft_ReserveSegment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SegmentRaftServer is the server API for SegmentRaft service.
+// All implementations must embed UnimplementedSegmentRaftServer
+// for forward compatibility.
+type SegmentRaftServer interface {
+	// ReserveSegment follower 将号段分配转发给 leader，由 leader 写入 Raft 日志
+	ReserveSegment(context.Context, *ReserveSegmentRequest) (*ReserveSegmentResponse, error)
+	mustEmbedUnimplementedSegmentRaftServer()
+}
+
+// UnimplementedSegmentRaftServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSegmentRaftServer struct{}
+
+func (UnimplementedSegmentRaftServer) ReserveSegment(context.Context, *ReserveSegmentRequest) (*ReserveSegmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveSegment not implemented")
+}
+func (UnimplementedSegmentRaftServer) mustEmbedUnimplementedSegmentRaftServer() {}
+func (UnimplementedSegmentRaftServer) testEmbeddedByValue()                     {}
+
+// UnsafeSegmentRaftServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SegmentRaftServer will
+// result in compilation errors.
+type UnsafeSegmentRaftServer interface {
+	mustEmbedUnimplementedSegmentRaftServer()
+}
+
+func RegisterSegmentRaftServer(s grpc.ServiceRegistrar, srv SegmentRaftServer) {
+	// If the following call pancis, it indicates UnimplementedSegmentRaftServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SegmentRaft_ServiceDesc, srv)
+}
+
+func _SegmentRaft_ReserveSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveSegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentRaftServer).ReserveSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SegmentRaft_ReserveSegment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentRaftServer).ReserveSegment(ctx, req.(*ReserveSegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SegmentRaft_ServiceDesc is the grpc.ServiceDesc for SegmentRaft service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SegmentRaft_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.SegmentRaft",
+	HandlerType: (*SegmentRaftServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReserveSegment",
+			Handler:    _SegmentRaft_ReserveSegment_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "segment_raft.proto",
+}
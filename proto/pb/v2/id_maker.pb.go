@@ -0,0 +1,575 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: v2/id_maker.proto
+
+package pbv2
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Generator ID 生成器类型
+type Generator int32
+
+const (
+	Generator_GENERATOR_UNSPECIFIED Generator = 0 // 未指定，请求中视为参数错误
+	Generator_GENERATOR_SNOWFLAKE   Generator = 1
+	Generator_GENERATOR_SEGMENT     Generator = 2
+	Generator_GENERATOR_ULID        Generator = 3 // 128 位，毫秒时间戳加随机数，同一节点内严格递增，无需配置
+	Generator_GENERATOR_UUIDV7      Generator = 4 // 128 位，RFC 9562 UUID version 7，同一节点内严格递增，无需配置
+)
+
+// Enum value maps for Generator.
+var (
+	Generator_name = map[int32]string{
+		0: "GENERATOR_UNSPECIFIED",
+		1: "GENERATOR_SNOWFLAKE",
+		2: "GENERATOR_SEGMENT",
+		3: "GENERATOR_ULID",
+		4: "GENERATOR_UUIDV7",
+	}
+	Generator_value = map[string]int32{
+		"GENERATOR_UNSPECIFIED": 0,
+		"GENERATOR_SNOWFLAKE":   1,
+		"GENERATOR_SEGMENT":     2,
+		"GENERATOR_ULID":        3,
+		"GENERATOR_UUIDV7":      4,
+	}
+)
+
+func (x Generator) Enum() *Generator {
+	p := new(Generator)
+	*p = x
+	return p
+}
+
+func (x Generator) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Generator) Descriptor() protoreflect.EnumDescriptor {
+	return file_v2_id_maker_proto_enumTypes[0].Descriptor()
+}
+
+func (Generator) Type() protoreflect.EnumType {
+	return &file_v2_id_maker_proto_enumTypes[0]
+}
+
+func (x Generator) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Generator.Descriptor instead.
+func (Generator) EnumDescriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{0}
+}
+
+// IntFormat 64 位整数 ID（snowflake、segment）的返回格式
+type IntFormat int32
+
+const (
+	IntFormat_INT_FORMAT_UNSPECIFIED IntFormat = 0 // 同 INT_FORMAT_INT64
+	IntFormat_INT_FORMAT_INT64       IntFormat = 1 // 返回在 GenerateResponse.ids 中
+	IntFormat_INT_FORMAT_STRING      IntFormat = 2 // 十进制字符串，返回在 GenerateResponse.id_strings 中，适合只支持 53 位整数精度的调用方
+)
+
+// Enum value maps for IntFormat.
+var (
+	IntFormat_name = map[int32]string{
+		0: "INT_FORMAT_UNSPECIFIED",
+		1: "INT_FORMAT_INT64",
+		2: "INT_FORMAT_STRING",
+	}
+	IntFormat_value = map[string]int32{
+		"INT_FORMAT_UNSPECIFIED": 0,
+		"INT_FORMAT_INT64":       1,
+		"INT_FORMAT_STRING":      2,
+	}
+)
+
+func (x IntFormat) Enum() *IntFormat {
+	p := new(IntFormat)
+	*p = x
+	return p
+}
+
+func (x IntFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (IntFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_v2_id_maker_proto_enumTypes[1].Descriptor()
+}
+
+func (IntFormat) Type() protoreflect.EnumType {
+	return &file_v2_id_maker_proto_enumTypes[1]
+}
+
+func (x IntFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use IntFormat.Descriptor instead.
+func (IntFormat) EnumDescriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{1}
+}
+
+type SnowflakeOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        IntFormat              `protobuf:"varint,1,opt,name=format,proto3,enum=mid.v2.IntFormat" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnowflakeOptions) Reset() {
+	*x = SnowflakeOptions{}
+	mi := &file_v2_id_maker_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnowflakeOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnowflakeOptions) ProtoMessage() {}
+
+func (x *SnowflakeOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_v2_id_maker_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnowflakeOptions.ProtoReflect.Descriptor instead.
+func (*SnowflakeOptions) Descriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SnowflakeOptions) GetFormat() IntFormat {
+	if x != nil {
+		return x.Format
+	}
+	return IntFormat_INT_FORMAT_UNSPECIFIED
+}
+
+type SegmentOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BizTag        string                 `protobuf:"bytes,1,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"` // 业务标识，为空时使用 "default"
+	Format        IntFormat              `protobuf:"varint,2,opt,name=format,proto3,enum=mid.v2.IntFormat" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SegmentOptions) Reset() {
+	*x = SegmentOptions{}
+	mi := &file_v2_id_maker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SegmentOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SegmentOptions) ProtoMessage() {}
+
+func (x *SegmentOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_v2_id_maker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SegmentOptions.ProtoReflect.Descriptor instead.
+func (*SegmentOptions) Descriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SegmentOptions) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
+func (x *SegmentOptions) GetFormat() IntFormat {
+	if x != nil {
+		return x.Format
+	}
+	return IntFormat_INT_FORMAT_UNSPECIFIED
+}
+
+type GenerateRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Generator Generator              `protobuf:"varint,1,opt,name=generator,proto3,enum=mid.v2.Generator" json:"generator,omitempty"`
+	// 生成器相关的选项，可省略；指定时须与 generator 一致。ULID 和 UUIDV7 没有选项
+	//
+	// Types that are valid to be assigned to Options:
+	//
+	//	*GenerateRequest_Snowflake
+	//	*GenerateRequest_Segment
+	Options       isGenerateRequest_Options `protobuf_oneof:"options"`
+	Count         int32                     `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"` // 需要的 ID 数量，0 表示 1 个，不能超过服务端上限
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateRequest) Reset() {
+	*x = GenerateRequest{}
+	mi := &file_v2_id_maker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateRequest) ProtoMessage() {}
+
+func (x *GenerateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_v2_id_maker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateRequest.ProtoReflect.Descriptor instead.
+func (*GenerateRequest) Descriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GenerateRequest) GetGenerator() Generator {
+	if x != nil {
+		return x.Generator
+	}
+	return Generator_GENERATOR_UNSPECIFIED
+}
+
+func (x *GenerateRequest) GetOptions() isGenerateRequest_Options {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *GenerateRequest) GetSnowflake() *SnowflakeOptions {
+	if x != nil {
+		if x, ok := x.Options.(*GenerateRequest_Snowflake); ok {
+			return x.Snowflake
+		}
+	}
+	return nil
+}
+
+func (x *GenerateRequest) GetSegment() *SegmentOptions {
+	if x != nil {
+		if x, ok := x.Options.(*GenerateRequest_Segment); ok {
+			return x.Segment
+		}
+	}
+	return nil
+}
+
+func (x *GenerateRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type isGenerateRequest_Options interface {
+	isGenerateRequest_Options()
+}
+
+type GenerateRequest_Snowflake struct {
+	Snowflake *SnowflakeOptions `protobuf:"bytes,2,opt,name=snowflake,proto3,oneof"`
+}
+
+type GenerateRequest_Segment struct {
+	Segment *SegmentOptions `protobuf:"bytes,3,opt,name=segment,proto3,oneof"`
+}
+
+func (*GenerateRequest_Snowflake) isGenerateRequest_Options() {}
+
+func (*GenerateRequest_Segment) isGenerateRequest_Options() {}
+
+// ResponseMetadata 生成这批 ID 的生成器和节点，便于排查问题
+type ResponseMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Generator     Generator              `protobuf:"varint,1,opt,name=generator,proto3,enum=mid.v2.Generator" json:"generator,omitempty"`
+	BizTag        string                 `protobuf:"bytes,2,opt,name=biz_tag,json=bizTag,proto3" json:"biz_tag,omitempty"`       // segment 生成器的业务标识
+	NodeId        string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`       // 节点标识，"<datacenter_id>-<machine_id>"
+	IssuedAt      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"` // 服务端发放这批 ID 的时间
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseMetadata) Reset() {
+	*x = ResponseMetadata{}
+	mi := &file_v2_id_maker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseMetadata) ProtoMessage() {}
+
+func (x *ResponseMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_v2_id_maker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseMetadata.ProtoReflect.Descriptor instead.
+func (*ResponseMetadata) Descriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ResponseMetadata) GetGenerator() Generator {
+	if x != nil {
+		return x.Generator
+	}
+	return Generator_GENERATOR_UNSPECIFIED
+}
+
+func (x *ResponseMetadata) GetBizTag() string {
+	if x != nil {
+		return x.BizTag
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetIssuedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return nil
+}
+
+type GenerateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Ids   []int64                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"` // format 为 INT64 时返回，segment 生成器下严格递增
+	// format 为 STRING 时返回十进制字符串；ULID 和 UUIDV7 生成器返回规范字符串
+	// （ULID 为 26 位 Crockford Base32，UUIDv7 为 8-4-4-4-12 格式的小写十六进制），字符串顺序即生成顺序
+	IdStrings     []string          `protobuf:"bytes,2,rep,name=id_strings,json=idStrings,proto3" json:"id_strings,omitempty"`
+	Metadata      *ResponseMetadata `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	IdBytes       [][]byte          `protobuf:"bytes,4,rep,name=id_bytes,json=idBytes,proto3" json:"id_bytes,omitempty"` // ULID 和 UUIDV7 生成器返回的 16 字节大端序 ID，与 id_strings 一一对应
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateResponse) Reset() {
+	*x = GenerateResponse{}
+	mi := &file_v2_id_maker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateResponse) ProtoMessage() {}
+
+func (x *GenerateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_v2_id_maker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateResponse.ProtoReflect.Descriptor instead.
+func (*GenerateResponse) Descriptor() ([]byte, []int) {
+	return file_v2_id_maker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GenerateResponse) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *GenerateResponse) GetIdStrings() []string {
+	if x != nil {
+		return x.IdStrings
+	}
+	return nil
+}
+
+func (x *GenerateResponse) GetMetadata() *ResponseMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *GenerateResponse) GetIdBytes() [][]byte {
+	if x != nil {
+		return x.IdBytes
+	}
+	return nil
+}
+
+var File_v2_id_maker_proto protoreflect.FileDescriptor
+
+const file_v2_id_maker_proto_rawDesc = "" +
+	"\n" +
+	"\x11v2/id_maker.proto\x12\x06mid.v2\x1a\x1fgoogle/protobuf/timestamp.proto\"=\n" +
+	"\x10SnowflakeOptions\x12)\n" +
+	"\x06format\x18\x01 \x01(\x0e2\x11.mid.v2.IntFormatR\x06format\"T\n" +
+	"\x0eSegmentOptions\x12\x17\n" +
+	"\abiz_tag\x18\x01 \x01(\tR\x06bizTag\x12)\n" +
+	"\x06format\x18\x02 \x01(\x0e2\x11.mid.v2.IntFormatR\x06format\"\xd1\x01\n" +
+	"\x0fGenerateRequest\x12/\n" +
+	"\tgenerator\x18\x01 \x01(\x0e2\x11.mid.v2.GeneratorR\tgenerator\x128\n" +
+	"\tsnowflake\x18\x02 \x01(\v2\x18.mid.v2.SnowflakeOptionsH\x00R\tsnowflake\x122\n" +
+	"\asegment\x18\x03 \x01(\v2\x16.mid.v2.SegmentOptionsH\x00R\asegment\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05countB\t\n" +
+	"\aoptions\"\xae\x01\n" +
+	"\x10ResponseMetadata\x12/\n" +
+	"\tgenerator\x18\x01 \x01(\x0e2\x11.mid.v2.GeneratorR\tgenerator\x12\x17\n" +
+	"\abiz_tag\x18\x02 \x01(\tR\x06bizTag\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\x127\n" +
+	"\tissued_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bissuedAt\"\x94\x01\n" +
+	"\x10GenerateResponse\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x03R\x03ids\x12\x1d\n" +
+	"\n" +
+	"id_strings\x18\x02 \x03(\tR\tidStrings\x124\n" +
+	"\bmetadata\x18\x03 \x01(\v2\x18.mid.v2.ResponseMetadataR\bmetadata\x12\x19\n" +
+	"\bid_bytes\x18\x04 \x03(\fR\aidBytes*\x80\x01\n" +
+	"\tGenerator\x12\x19\n" +
+	"\x15GENERATOR_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13GENERATOR_SNOWFLAKE\x10\x01\x12\x15\n" +
+	"\x11GENERATOR_SEGMENT\x10\x02\x12\x12\n" +
+	"\x0eGENERATOR_ULID\x10\x03\x12\x14\n" +
+	"\x10GENERATOR_UUIDV7\x10\x04*T\n" +
+	"\tIntFormat\x12\x1a\n" +
+	"\x16INT_FORMAT_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10INT_FORMAT_INT64\x10\x01\x12\x15\n" +
+	"\x11INT_FORMAT_STRING\x10\x022L\n" +
+	"\vIDGenerator\x12=\n" +
+	"\bGenerate\x12\x17.mid.v2.GenerateRequest\x1a\x18.mid.v2.GenerateResponseB\x0eZ\f./pb/v2;pbv2b\x06proto3"
+
+var (
+	file_v2_id_maker_proto_rawDescOnce sync.Once
+	file_v2_id_maker_proto_rawDescData []byte
+)
+
+func file_v2_id_maker_proto_rawDescGZIP() []byte {
+	file_v2_id_maker_proto_rawDescOnce.Do(func() {
+		file_v2_id_maker_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_v2_id_maker_proto_rawDesc), len(file_v2_id_maker_proto_rawDesc)))
+	})
+	return file_v2_id_maker_proto_rawDescData
+}
+
+var file_v2_id_maker_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_v2_id_maker_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_v2_id_maker_proto_goTypes = []any{
+	(Generator)(0),                // 0: mid.v2.Generator
+	(IntFormat)(0),                // 1: mid.v2.IntFormat
+	(*SnowflakeOptions)(nil),      // 2: mid.v2.SnowflakeOptions
+	(*SegmentOptions)(nil),        // 3: mid.v2.SegmentOptions
+	(*GenerateRequest)(nil),       // 4: mid.v2.GenerateRequest
+	(*ResponseMetadata)(nil),      // 5: mid.v2.ResponseMetadata
+	(*GenerateResponse)(nil),      // 6: mid.v2.GenerateResponse
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_v2_id_maker_proto_depIdxs = []int32{
+	1, // 0: mid.v2.SnowflakeOptions.format:type_name -> mid.v2.IntFormat
+	1, // 1: mid.v2.SegmentOptions.format:type_name -> mid.v2.IntFormat
+	0, // 2: mid.v2.GenerateRequest.generator:type_name -> mid.v2.Generator
+	2, // 3: mid.v2.GenerateRequest.snowflake:type_name -> mid.v2.SnowflakeOptions
+	3, // 4: mid.v2.GenerateRequest.segment:type_name -> mid.v2.SegmentOptions
+	0, // 5: mid.v2.ResponseMetadata.generator:type_name -> mid.v2.Generator
+	7, // 6: mid.v2.ResponseMetadata.issued_at:type_name -> google.protobuf.Timestamp
+	5, // 7: mid.v2.GenerateResponse.metadata:type_name -> mid.v2.ResponseMetadata
+	4, // 8: mid.v2.IDGenerator.Generate:input_type -> mid.v2.GenerateRequest
+	6, // 9: mid.v2.IDGenerator.Generate:output_type -> mid.v2.GenerateResponse
+	9, // [9:10] is the sub-list for method output_type
+	8, // [8:9] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_v2_id_maker_proto_init() }
+func file_v2_id_maker_proto_init() {
+	if File_v2_id_maker_proto != nil {
+		return
+	}
+	file_v2_id_maker_proto_msgTypes[2].OneofWrappers = []any{
+		(*GenerateRequest_Snowflake)(nil),
+		(*GenerateRequest_Segment)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_v2_id_maker_proto_rawDesc), len(file_v2_id_maker_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_v2_id_maker_proto_goTypes,
+		DependencyIndexes: file_v2_id_maker_proto_depIdxs,
+		EnumInfos:         file_v2_id_maker_proto_enumTypes,
+		MessageInfos:      file_v2_id_maker_proto_msgTypes,
+	}.Build()
+	File_v2_id_maker_proto = out.File
+	file_v2_id_maker_proto_goTypes = nil
+	file_v2_id_maker_proto_depIdxs = nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/mazezen/mid/proto/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// errNoRaftLeader 集群未达到多数派或正在选举
+var errNoRaftLeader = errors.New("raft cluster has no leader")
+
+// raftAllocator 由多个 mid 节点组成 Raft 集群复制各业务标识的 max_id，
+// 只要多数派节点存活就能继续分配号段，消除单个 MySQL 的单点故障。
+//
+// 号段分配只能由 leader 写入 Raft 日志；follower 通过内部 gRPC 接口 SegmentRaft 转发给 leader，
+// 该接口没有鉴权，只在 internal_addr 上监听，不注册到业务方使用的 gRPC 端口。
+// 分配请求超时或 leader 切换时，日志可能已提交但调用方收到错误，重试会分配新的号段，只会浪费号段而不会重复。
+type raftAllocator struct {
+	pb.UnimplementedSegmentRaftServer
+
+	nodeID       string
+	steps        map[string]int64  // 配置的业务标识及其基础步长
+	forwardAddrs map[string]string // 节点 ID -> 内部转发地址
+	maxStep      int64             // 转发请求的步长上限，业务标识的基础步长更大时以基础步长为准
+	applyTimeout time.Duration
+	internal     *grpc.Server // 内部转发接口
+
+	raft      *raft.Raft
+	fsm       *segmentFSM
+	transport *raft.NetworkTransport
+	store     *raftboltdb.BoltStore
+
+	connsMu sync.Mutex
+	conns   map[string]*grpc.ClientConn // 到其他节点的转发连接
+}
+
+// openRaftAllocator 启动本节点的 Raft 实例。peers 为静态的集群成员，
+// 首次启动时用其初始化集群配置，已有数据时从本地日志和快照恢复
+func openRaftAllocator(cfg RaftConfig, maxStep int64) (*raftAllocator, error) {
+	steps, err := parseBizTags(cfg.BizTags, cfg.Step)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := parsePeers(cfg.Peers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raft peers: %v", err)
+	}
+	forwardAddrs, err := parsePeers(cfg.ForwardAddrs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raft forward addrs: %v", err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create raft data dir: %v", err)
+	}
+	internalLis, err := net.Listen("tcp", cfg.InternalAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", cfg.InternalAddr, err)
+	}
+
+	logOutput := zap.NewStdLog(mLog.Named("raft")).Writer()
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(cfg.NodeID)
+	conf.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:        "raft",
+		Level:       hclog.Info,
+		Output:      logOutput,
+		DisableTime: true, // 时间由 zap 记录
+	})
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(cfg.DataDir, "raft.db"))
+	if err != nil {
+		internalLis.Close()
+		return nil, fmt.Errorf("failed to open raft store: %v", err)
+	}
+	snapshots, err := raft.NewFileSnapshotStore(cfg.DataDir, 2, logOutput)
+	if err != nil {
+		store.Close()
+		internalLis.Close()
+		return nil, fmt.Errorf("failed to open raft snapshot store: %v", err)
+	}
+	advertise, err := net.ResolveTCPAddr("tcp", peers[cfg.NodeID])
+	if err != nil {
+		store.Close()
+		internalLis.Close()
+		return nil, fmt.Errorf("invalid raft address %s: %v", peers[cfg.NodeID], err)
+	}
+	bindAddr := cfg.BindAddr
+	if bindAddr == "" {
+		bindAddr = peers[cfg.NodeID]
+	}
+	transport, err := raft.NewTCPTransport(bindAddr, advertise, 3, 10*time.Second, logOutput)
+	if err != nil {
+		store.Close()
+		internalLis.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %v", bindAddr, err)
+	}
+
+	fsm := &segmentFSM{maxIDs: make(map[string]int64)}
+	r, err := raft.NewRaft(conf, fsm, store, store, snapshots, transport)
+	if err != nil {
+		transport.Close()
+		store.Close()
+		internalLis.Close()
+		return nil, fmt.Errorf("failed to start raft: %v", err)
+	}
+
+	// 每个节点都用同一份静态配置初始化，已有状态时返回 ErrCantBootstrap
+	var servers []raft.Server
+	for id, addr := range peers {
+		servers = append(servers, raft.Server{ID: raft.ServerID(id), Address: raft.ServerAddress(addr)})
+	}
+	if err := r.BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+		r.Shutdown()
+		transport.Close()
+		store.Close()
+		internalLis.Close()
+		return nil, fmt.Errorf("failed to bootstrap raft cluster: %v", err)
+	}
+
+	a := &raftAllocator{
+		nodeID:       cfg.NodeID,
+		steps:        steps,
+		forwardAddrs: forwardAddrs,
+		maxStep:      maxStep,
+		applyTimeout: cfg.ApplyTimeout,
+		internal:     grpc.NewServer(),
+		raft:         r,
+		fsm:          fsm,
+		transport:    transport,
+		store:        store,
+		conns:        make(map[string]*grpc.ClientConn),
+	}
+	pb.RegisterSegmentRaftServer(a.internal, a)
+	go func() {
+		if err := a.internal.Serve(internalLis); err != nil {
+			mLog.Error("Raft internal listener stopped", zap.Error(err))
+		}
+	}()
+
+	mLog.Info("Raft node started",
+		zap.String("node_id", cfg.NodeID),
+		zap.String("bind_addr", bindAddr),
+		zap.String("internal_addr", internalLis.Addr().String()),
+		zap.Int("peers", len(peers)))
+	return a, nil
+}
+
+// parsePeers 解析 "n1=127.0.0.1:7001,n2=127.0.0.1:7002" 形式的节点列表
+func parsePeers(s string) (map[string]string, error) {
+	peers := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, addr, ok := strings.Cut(item, "=")
+		if !ok || id == "" || addr == "" {
+			return nil, fmt.Errorf("invalid peer %q, want id=host:port", item)
+		}
+		peers[id] = addr
+	}
+	if len(peers) == 0 {
+		return nil, errors.New("no peers configured")
+	}
+	return peers, nil
+}
+
+func (a *raftAllocator) Backend() string {
+	return backendRaft
+}
+
+func (a *raftAllocator) Step(ctx context.Context, bizTag string) (int64, error) {
+	step, ok := a.steps[bizTag]
+	if !ok {
+		return 0, errBizTagNotFound
+	}
+	return step, nil
+}
+
+// Reserve 本节点为 leader 时写入 Raft 日志，否则转发给 leader
+func (a *raftAllocator) Reserve(ctx context.Context, bizTag string, step int64) (int64, error) {
+	if _, ok := a.steps[bizTag]; !ok {
+		return 0, errBizTagNotFound
+	}
+	if a.raft.State() == raft.Leader {
+		return a.apply(bizTag, step)
+	}
+
+	_, leaderID := a.raft.LeaderWithID()
+	if leaderID == "" {
+		return 0, errNoRaftLeader
+	}
+	client, err := a.client(string(leaderID))
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.applyTimeout)
+	defer cancel()
+	resp, err := client.ReserveSegment(ctx, &pb.ReserveSegmentRequest{BizTag: bizTag, Step: step})
+	if err != nil {
+		return 0, forwardError(string(leaderID), err)
+	}
+	return resp.MaxId, nil
+}
+
+// forwardError 将 leader 返回的 gRPC 状态还原为本地的哨兵错误，调用方看到的错误码与直接在 leader 上分配时一致
+func forwardError(leaderID string, err error) error {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.NotFound:
+		return errBizTagNotFound
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return fmt.Errorf("%w: leader %s: %s", errInvalidStep, leaderID, st.Message())
+	}
+	return fmt.Errorf("failed to forward reservation to leader %s: %w", leaderID, err)
+}
+
+// ReserveSegment 处理 follower 转发的分配请求。本节点已不是 leader 时不再转发，由调用方重试
+func (a *raftAllocator) ReserveSegment(ctx context.Context, req *pb.ReserveSegmentRequest) (*pb.ReserveSegmentResponse, error) {
+	baseStep, ok := a.steps[req.BizTag]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "biz_tag %q not found", req.BizTag)
+	}
+	if limit := max(a.maxStep, baseStep); req.Step <= 0 || req.Step > limit {
+		return nil, status.Errorf(codes.InvalidArgument, "step must be between 1 and %d", limit)
+	}
+	if a.raft.State() != raft.Leader {
+		return nil, status.Errorf(codes.Unavailable, "node %s is not the raft leader", a.nodeID)
+	}
+	maxID, err := a.apply(req.BizTag, req.Step)
+	if errors.Is(err, errInvalidStep) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.ReserveSegmentResponse{MaxId: maxID}, nil
+}
+
+func (a *raftAllocator) apply(bizTag string, step int64) (int64, error) {
+	cmd, err := json.Marshal(reserveCommand{BizTag: bizTag, Step: step})
+	if err != nil {
+		return 0, err
+	}
+	f := a.raft.Apply(cmd, a.applyTimeout)
+	if err := f.Error(); err != nil {
+		return 0, fmt.Errorf("failed to apply raft log: %v", err)
+	}
+	if err, ok := f.Response().(error); ok {
+		return 0, err
+	}
+	return f.Response().(int64), nil
+}
+
+// client 返回到 nodeID 的转发连接，首次使用时创建
+func (a *raftAllocator) client(nodeID string) (pb.SegmentRaftClient, error) {
+	a.connsMu.Lock()
+	defer a.connsMu.Unlock()
+	if conn, ok := a.conns[nodeID]; ok {
+		return pb.NewSegmentRaftClient(conn), nil
+	}
+	addr, ok := a.forwardAddrs[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("no forward address for raft node %s", nodeID)
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	a.conns[nodeID] = conn
+	return pb.NewSegmentRaftClient(conn), nil
+}
+
+// Ping 集群存在 leader 时可用
+func (a *raftAllocator) Ping(ctx context.Context) error {
+	if addr, _ := a.raft.LeaderWithID(); addr == "" {
+		return errNoRaftLeader
+	}
+	return nil
+}
+
+// Close 本节点为 leader 时先转移领导权，缩短其他节点的不可用时间
+func (a *raftAllocator) Close() error {
+	if a.raft.State() == raft.Leader {
+		if err := a.raft.LeadershipTransfer().Error(); err != nil {
+			mLog.Warn("Failed to transfer raft leadership", zap.Error(err))
+		}
+	}
+	a.internal.Stop()
+	err := a.raft.Shutdown().Error()
+	a.transport.Close()
+	a.store.Close()
+
+	a.connsMu.Lock()
+	for _, conn := range a.conns {
+		conn.Close()
+	}
+	a.connsMu.Unlock()
+	return err
+}
+
+// reserveCommand Raft 日志中的一条号段分配
+type reserveCommand struct {
+	BizTag string `json:"biz_tag"`
+	Step   int64  `json:"step"`
+}
+
+// segmentFSM 由 Raft 日志驱动的状态机，保存各业务标识的 max_id
+type segmentFSM struct {
+	mu     sync.Mutex
+	maxIDs map[string]int64
+}
+
+// Apply 返回分配后的 max_id。步长不是正数或 max_id 会溢出时不修改状态，返回 errInvalidStep；
+// 各节点按相同顺序重放相同的日志，拒绝的结果在所有节点上一致
+func (f *segmentFSM) Apply(l *raft.Log) interface{} {
+	var cmd reserveCommand
+	if err := json.Unmarshal(l.Data, &cmd); err != nil {
+		// 日志由本程序写入，解析失败说明版本不兼容，继续运行可能重复发号
+		panic(fmt.Sprintf("invalid raft log at index %d: %v", l.Index, err))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	maxID := f.maxIDs[cmd.BizTag]
+	if cmd.Step <= 0 || maxID > math.MaxInt64-cmd.Step {
+		return fmt.Errorf("%w %d for biz_tag %s: max_id %d would overflow", errInvalidStep, cmd.Step, cmd.BizTag, maxID)
+	}
+	f.maxIDs[cmd.BizTag] = maxID + cmd.Step
+	return f.maxIDs[cmd.BizTag]
+}
+
+func (f *segmentFSM) Snapshot() (raft.FSMSnapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	maxIDs := make(map[string]int64, len(f.maxIDs))
+	for tag, maxID := range f.maxIDs {
+		maxIDs[tag] = maxID
+	}
+	return &segmentSnapshot{maxIDs: maxIDs}, nil
+}
+
+func (f *segmentFSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	maxIDs := make(map[string]int64)
+	if err := json.NewDecoder(rc).Decode(&maxIDs); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.maxIDs = maxIDs
+	f.mu.Unlock()
+	return nil
+}
+
+type segmentSnapshot struct {
+	maxIDs map[string]int64
+}
+
+func (s *segmentSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.maxIDs); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *segmentSnapshot) Release() {}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/mazezen/mid/proto/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// raftTestNode 一个进程内的 Raft 节点
+type raftTestNode struct {
+	alloc *raftAllocator
+}
+
+func (n *raftTestNode) stop() {
+	n.alloc.Close()
+}
+
+// freeAddr 返回一个空闲的回环地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startRaftCluster 在回环地址上启动 size 个节点
+func startRaftCluster(t *testing.T, size int) []*raftTestNode {
+	t.Helper()
+	mLog = zap.NewNop()
+
+	var peers, internalAddrs []string
+	for i := 0; i < size; i++ {
+		peers = append(peers, fmt.Sprintf("n%d=%s", i, freeAddr(t)))
+		internalAddrs = append(internalAddrs, freeAddr(t))
+	}
+	var forwardAddrs []string
+	for i, addr := range internalAddrs {
+		forwardAddrs = append(forwardAddrs, fmt.Sprintf("n%d=%s", i, addr))
+	}
+
+	nodes := make([]*raftTestNode, size)
+	for i := range nodes {
+		alloc, err := openRaftAllocator(RaftConfig{
+			NodeID:       fmt.Sprintf("n%d", i),
+			Peers:        strings.Join(peers, ","),
+			InternalAddr: internalAddrs[i],
+			ForwardAddrs: strings.Join(forwardAddrs, ","),
+			DataDir:      t.TempDir(),
+			BizTags:      "default",
+			Step:         10,
+			ApplyTimeout: 5 * time.Second,
+		}, 1000)
+		if err != nil {
+			t.Fatalf("openRaftAllocator n%d: %v", i, err)
+		}
+		nodes[i] = &raftTestNode{alloc: alloc}
+	}
+	return nodes
+}
+
+// waitLeader 等待 nodes 中选出 leader 并被所有节点知晓
+func waitLeader(t *testing.T, nodes []*raftTestNode) *raftTestNode {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		var leader *raftTestNode
+		known := 0
+		for _, n := range nodes {
+			if n.alloc.raft.State() == raft.Leader {
+				leader = n
+			}
+			if n.alloc.Ping(context.Background()) == nil {
+				known++
+			}
+		}
+		if leader != nil && known == len(nodes) {
+			return leader
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("no raft leader elected")
+	return nil
+}
+
+func TestRaftAllocatorReplicatesAcrossNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a 3-node raft cluster")
+	}
+	nodes := startRaftCluster(t, 3)
+	stopped := make(map[*raftTestNode]bool)
+	defer func() {
+		for _, n := range nodes {
+			if !stopped[n] {
+				n.stop()
+			}
+		}
+	}()
+	leader := waitLeader(t, nodes)
+
+	// 所有节点并发分配，follower 转发给 leader，号段互不重叠且连续
+	const rounds = 20
+	var (
+		mu   sync.Mutex
+		maxs = make(map[int64]bool)
+		wg   sync.WaitGroup
+	)
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *raftTestNode) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				newMax, err := n.alloc.Reserve(context.Background(), defaultBizTag, 10)
+				if err != nil {
+					t.Errorf("Reserve on %s: %v", n.alloc.nodeID, err)
+					return
+				}
+				mu.Lock()
+				if maxs[newMax] {
+					t.Errorf("segment ending at %d reserved twice", newMax)
+				}
+				maxs[newMax] = true
+				mu.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+	total := int64(len(nodes) * rounds)
+	for i := int64(1); i <= total; i++ {
+		if !maxs[i*10] {
+			t.Fatalf("segment ending at %d missing", i*10)
+		}
+	}
+
+	// 停掉 leader，剩余两个节点仍是多数派，重新选主后继续分配，不会回退
+	leader.stop()
+	stopped[leader] = true
+	var rest []*raftTestNode
+	for _, n := range nodes {
+		if n != leader {
+			rest = append(rest, n)
+		}
+	}
+	waitLeader(t, rest)
+	for _, n := range rest {
+		var newMax int64
+		var err error
+		// 选主后 follower 可能还在连接新 leader，短暂重试
+		for i := 0; i < 50; i++ {
+			if newMax, err = n.alloc.Reserve(context.Background(), defaultBizTag, 10); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("Reserve on %s after failover: %v", n.alloc.nodeID, err)
+		}
+		if newMax <= total*10 {
+			t.Fatalf("Reserve on %s after failover = %d, want > %d", n.alloc.nodeID, newMax, total*10)
+		}
+	}
+}
+
+func TestRaftReserveSegmentLimitsStep(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a 3-node raft cluster")
+	}
+	nodes := startRaftCluster(t, 3)
+	defer func() {
+		for _, n := range nodes {
+			n.stop()
+		}
+	}()
+	leader := waitLeader(t, nodes)
+
+	for _, step := range []int64{0, -1, 1001, math.MaxInt64} {
+		_, err := leader.alloc.ReserveSegment(context.Background(), &pb.ReserveSegmentRequest{BizTag: defaultBizTag, Step: step})
+		if code := status.Code(err); code != codes.InvalidArgument {
+			t.Fatalf("step %d: code = %v, want InvalidArgument", step, code)
+		}
+	}
+	if _, err := leader.alloc.ReserveSegment(context.Background(), &pb.ReserveSegmentRequest{BizTag: defaultBizTag, Step: 1000}); err != nil {
+		t.Fatalf("step at max_step: %v", err)
+	}
+}
+
+func TestSegmentFSMRejectsOverflow(t *testing.T) {
+	fsm := &segmentFSM{maxIDs: map[string]int64{defaultBizTag: math.MaxInt64 - 10}}
+	apply := func(step int64) interface{} {
+		data, _ := json.Marshal(reserveCommand{BizTag: defaultBizTag, Step: step})
+		return fsm.Apply(&raft.Log{Index: 1, Data: data})
+	}
+
+	if got := apply(10); got != int64(math.MaxInt64) {
+		t.Fatalf("Apply(10) = %v, want %d", got, int64(math.MaxInt64))
+	}
+	for _, step := range []int64{1, 0, -5} {
+		err, ok := apply(step).(error)
+		if !ok || !errors.Is(err, errInvalidStep) {
+			t.Fatalf("Apply(%d) = %v, want errInvalidStep", step, err)
+		}
+	}
+	if got := fsm.maxIDs[defaultBizTag]; got != math.MaxInt64 {
+		t.Fatalf("rejected entries changed max_id to %d", got)
+	}
+}
+
+func TestRaftForwardKeepsErrorCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a 3-node raft cluster")
+	}
+	nodes := startRaftCluster(t, 3)
+	defer func() {
+		for _, n := range nodes {
+			n.stop()
+		}
+	}()
+	leader := waitLeader(t, nodes)
+	var follower *raftTestNode
+	for _, n := range nodes {
+		if n != leader {
+			follower = n
+			break
+		}
+	}
+	// 模拟各节点 biz_tags 配置不一致：只有 follower 配置了 order
+	follower.alloc.steps["order"] = 10
+
+	tests := []struct {
+		name   string
+		bizTag string
+		step   int64
+		code   codes.Code
+		reason string
+	}{
+		{"biz_tag missing on leader", "order", 10, codes.NotFound, reasonBizTagNotFound},
+		{"step above max_step", defaultBizTag, 5000, codes.FailedPrecondition, reasonInvalidStep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := follower.alloc.Reserve(context.Background(), tt.bizTag, tt.step)
+			if err == nil {
+				t.Fatal("forwarded reservation succeeded")
+			}
+			st := status.Convert(generateError(err, "segment", tt.bizTag))
+			if st.Code() != tt.code || errorReason(st) != tt.reason {
+				t.Fatalf("forwarded error %v became %v %s, want %v %s", err, st.Code(), errorReason(st), tt.code, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errLeaseLost 机器 ID 租约已失效，继续生成可能与其他节点产生重复 ID
+var errLeaseLost = errors.New("worker id lease lost")
+
+// WorkerRegistry 基于 MySQL 的机器 ID 注册表
+//
+// 节点启动时在 worker_nodes 表中抢占一个空闲（或租约已过期）的机器 ID，
+// 之后定期续约；续约失败且超过租约期限后视为租约丢失，Snowflake 拒绝继续生成 ID。
+// 租约过期时间使用 MySQL 的时钟计算，不依赖各节点本地时钟一致。
+type WorkerRegistry struct {
+	db           *sql.DB
+	datacenterID int64
+	machineID    int64
+	maxMachine   int64 // 由位布局决定的最大机器 ID
+	instanceID   string
+	ttl          time.Duration
+
+	mu       sync.Mutex
+	deadline time.Time // 本地估算的租约到期时间
+	lost     bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWorkerRegistry 在 datacenterID 下抢占一个不超过 maxMachine 的空闲机器 ID 并开始续约
+func NewWorkerRegistry(db *sql.DB, datacenterID, maxMachine int64, ttl time.Duration) (*WorkerRegistry, error) {
+	r := &WorkerRegistry{
+		db:           db,
+		datacenterID: datacenterID,
+		maxMachine:   maxMachine,
+		instanceID:   newInstanceID(),
+		ttl:          ttl,
+		done:         make(chan struct{}),
+	}
+	if err := r.claim(); err != nil {
+		return nil, err
+	}
+	mLog.Info("Claimed worker id",
+		zap.Int64("datacenter_id", r.datacenterID),
+		zap.Int64("machine_id", r.machineID),
+		zap.String("instance_id", r.instanceID),
+		zap.Duration("ttl", ttl))
+
+	r.wg.Add(1)
+	go r.heartbeat()
+	return r, nil
+}
+
+// newInstanceID 生成当前进程的唯一标识，写入租约行便于排查
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// claim 依次尝试每个机器 ID：不存在则插入，已过期则接管
+func (r *WorkerRegistry) claim() error {
+	ttlMicros := r.ttl.Microseconds()
+	for machineID := int64(0); machineID <= r.maxMachine; machineID++ {
+		start := time.Now()
+		result, err := r.db.Exec(
+			"INSERT IGNORE INTO worker_nodes (datacenter_id, machine_id, instance_id, lease_expires_at) "+
+				"VALUES (?, ?, ?, NOW(3) + INTERVAL ? MICROSECOND)",
+			r.datacenterID, machineID, r.instanceID, ttlMicros,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to claim worker id: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			r.machineID = machineID
+			r.renewed(start)
+			return nil
+		}
+
+		result, err = r.db.Exec(
+			"UPDATE worker_nodes SET instance_id = ?, lease_expires_at = NOW(3) + INTERVAL ? MICROSECOND "+
+				"WHERE datacenter_id = ? AND machine_id = ? AND lease_expires_at < NOW(3)",
+			r.instanceID, ttlMicros, r.datacenterID, machineID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to claim worker id: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			r.machineID = machineID
+			r.renewed(start)
+			return nil
+		}
+	}
+	return fmt.Errorf("no free worker id in datacenter %d", r.datacenterID)
+}
+
+// renewed 以发起请求的时间为起点更新本地租约期限，保证不晚于数据库中的到期时间
+func (r *WorkerRegistry) renewed(start time.Time) {
+	r.mu.Lock()
+	r.deadline = start.Add(r.ttl)
+	r.mu.Unlock()
+}
+
+// heartbeat 每 ttl/3 续约一次
+func (r *WorkerRegistry) heartbeat() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		if err := r.renew(); err != nil {
+			mLog.Error("Failed to renew worker id lease",
+				zap.Int64("machine_id", r.machineID),
+				zap.Error(err))
+			if errors.Is(err, errLeaseLost) {
+				return
+			}
+		}
+	}
+}
+
+func (r *WorkerRegistry) renew() error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), r.ttl/3)
+	defer cancel()
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE worker_nodes SET lease_expires_at = NOW(3) + INTERVAL ? MICROSECOND "+
+			"WHERE datacenter_id = ? AND machine_id = ? AND instance_id = ?",
+		r.ttl.Microseconds(), r.datacenterID, r.machineID, r.instanceID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n != 1 {
+		// 租约已被其他节点接管
+		r.mu.Lock()
+		r.lost = true
+		r.mu.Unlock()
+		return errLeaseLost
+	}
+	r.renewed(start)
+	return nil
+}
+
+// Valid 租约是否仍然有效
+func (r *WorkerRegistry) Valid() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.lost && time.Now().Before(r.deadline)
+}
+
+func (r *WorkerRegistry) DatacenterID() int64 {
+	return r.datacenterID
+}
+
+func (r *WorkerRegistry) MachineID() int64 {
+	return r.machineID
+}
+
+// Load 读取该机器 ID 上次使用的时间戳高水位，实现 timestampStore
+func (r *WorkerRegistry) Load() (int64, error) {
+	var ts int64
+	err := r.db.QueryRow(
+		"SELECT last_timestamp FROM worker_nodes WHERE datacenter_id = ? AND machine_id = ?",
+		r.datacenterID, r.machineID,
+	).Scan(&ts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load last_timestamp: %v", err)
+	}
+	return ts, nil
+}
+
+// Save 将时间戳高水位写入租约行，机器 ID 被其他节点接管后高水位随之转移
+func (r *WorkerRegistry) Save(ctx context.Context, ts int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE worker_nodes SET last_timestamp = ? WHERE datacenter_id = ? AND machine_id = ? AND instance_id = ?",
+		ts, r.datacenterID, r.machineID, r.instanceID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save last_timestamp: %v", err)
+	}
+	return nil
+}
+
+// Close 停止续约并释放机器 ID。保留租约行中的 last_timestamp，供下一个使用该机器 ID 的节点恢复
+func (r *WorkerRegistry) Close() error {
+	close(r.done)
+	r.wg.Wait()
+
+	r.mu.Lock()
+	r.lost = true
+	r.mu.Unlock()
+
+	_, err := r.db.Exec(
+		"UPDATE worker_nodes SET lease_expires_at = NOW(3) WHERE datacenter_id = ? AND machine_id = ? AND instance_id = ?",
+		r.datacenterID, r.machineID, r.instanceID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release worker id: %v", err)
+	}
+	mLog.Info("Released worker id",
+		zap.Int64("datacenter_id", r.datacenterID),
+		zap.Int64("machine_id", r.machineID))
+	return nil
+}
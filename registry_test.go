@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+// 测试中租约足够长，心跳协程不会续约，由测试直接调用 renew
+const testLeaseTTL = time.Hour
+
+// worker_nodes 上的语句，sqlmock 按正则匹配
+const (
+	claimInsertSQL   = `INSERT IGNORE INTO worker_nodes`
+	claimTakeoverSQL = `UPDATE worker_nodes SET instance_id = \?`
+	renewSQL         = `UPDATE worker_nodes SET lease_expires_at = NOW\(3\) \+ INTERVAL`
+	releaseSQL       = `UPDATE worker_nodes SET lease_expires_at = NOW\(3\) WHERE`
+	loadSQL          = `SELECT last_timestamp FROM worker_nodes`
+	saveSQL          = `UPDATE worker_nodes SET last_timestamp = \?`
+)
+
+// newRegistryDB 返回 sqlmock 连接，测试结束时检查预期的语句都已按顺序执行
+func newRegistryDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	mLog = zap.NewNop()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+// expectClaimed machineID 之前的机器 ID 都被其他节点持有且未过期，machineID 空闲
+func expectClaimed(mock sqlmock.Sqlmock, machineID int64) {
+	for id := int64(0); id < machineID; id++ {
+		mock.ExpectExec(claimInsertSQL).WithArgs(3, id, sqlmock.AnyArg(), testLeaseTTL.Microseconds()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(claimTakeoverSQL).WithArgs(sqlmock.AnyArg(), testLeaseTTL.Microseconds(), 3, id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(claimInsertSQL).WithArgs(3, machineID, sqlmock.AnyArg(), testLeaseTTL.Microseconds()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestWorkerRegistryClaimSkipsHeldIDs(t *testing.T) {
+	db, mock := newRegistryDB(t)
+	expectClaimed(mock, 2)
+	mock.ExpectExec(releaseSQL).WithArgs(3, 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.DatacenterID() != 3 || r.MachineID() != 2 || !r.Valid() {
+		t.Fatalf("claimed datacenter %d machine %d valid %v, want 3, 2, true", r.DatacenterID(), r.MachineID(), r.Valid())
+	}
+
+	// 释放时只让租约立即过期，保留 last_timestamp；释放后不能再用它生成 ID
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid() {
+		t.Fatal("lease still valid after Close")
+	}
+}
+
+func TestWorkerRegistryTakesOverExpiredLease(t *testing.T) {
+	db, mock := newRegistryDB(t)
+	mock.ExpectExec(claimInsertSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(claimTakeoverSQL).WithArgs(sqlmock.AnyArg(), testLeaseTTL.Microseconds(), 3, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(releaseSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.MachineID() != 0 || !r.Valid() {
+		t.Fatalf("took over machine %d valid %v, want 0, true", r.MachineID(), r.Valid())
+	}
+}
+
+func TestWorkerRegistryNoFreeID(t *testing.T) {
+	db, mock := newRegistryDB(t)
+	// 位布局只允许机器 ID 0 和 1
+	for id := int64(0); id <= 1; id++ {
+		mock.ExpectExec(claimInsertSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(claimTakeoverSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	if _, err := NewWorkerRegistry(db, 3, 1, testLeaseTTL); err == nil {
+		t.Fatal("claimed a worker id while all of them are held")
+	}
+
+	db, mock = newRegistryDB(t)
+	mock.ExpectExec(claimInsertSQL).WillReturnError(errors.New("connection refused"))
+	if _, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL); err == nil {
+		t.Fatal("claimed a worker id while mysql is down")
+	}
+}
+
+func TestWorkerRegistryRenew(t *testing.T) {
+	db, mock := newRegistryDB(t)
+	expectClaimed(mock, 0)
+	r, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mock.ExpectExec(releaseSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+		r.Close()
+	}()
+
+	// 续约成功后本地租约期限向后推移
+	r.mu.Lock()
+	r.deadline = time.Now().Add(time.Second)
+	r.mu.Unlock()
+	mock.ExpectExec(renewSQL).WithArgs(testLeaseTTL.Microseconds(), 3, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := r.renew(); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	deadline := r.deadline
+	r.mu.Unlock()
+	if time.Until(deadline) < testLeaseTTL-time.Minute {
+		t.Fatalf("deadline %v not extended by the lease ttl", deadline)
+	}
+
+	// 续约请求失败时租约在期限内仍然有效
+	mock.ExpectExec(renewSQL).WillReturnError(errors.New("connection refused"))
+	if err := r.renew(); err == nil || errors.Is(err, errLeaseLost) {
+		t.Fatalf("renew error = %v, want the mysql error", err)
+	}
+	if !r.Valid() {
+		t.Fatal("lease invalid before its deadline")
+	}
+}
+
+// 租约被其他节点接管或过期后 Snowflake 拒绝生成 ID
+func TestWorkerRegistryLeaseLost(t *testing.T) {
+	tests := []struct {
+		name string
+		lose func(t *testing.T, r *WorkerRegistry, mock sqlmock.Sqlmock)
+	}{
+		{"taken over", func(t *testing.T, r *WorkerRegistry, mock sqlmock.Sqlmock) {
+			mock.ExpectExec(renewSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+			if err := r.renew(); !errors.Is(err, errLeaseLost) {
+				t.Fatalf("renew error = %v, want errLeaseLost", err)
+			}
+		}},
+		{"expired", func(t *testing.T, r *WorkerRegistry, mock sqlmock.Sqlmock) {
+			r.mu.Lock()
+			r.deadline = time.Now().Add(-time.Millisecond)
+			r.mu.Unlock()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newRegistryDB(t)
+			expectClaimed(mock, 1)
+			r, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				mock.ExpectExec(releaseSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+				r.Close()
+			}()
+			snowflake, err := NewSnowflakeWithRegistry(r, DefaultLayout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := snowflake.NextID(); err != nil {
+				t.Fatal(err)
+			}
+			if err := snowflake.Healthy(); err != nil {
+				t.Fatalf("Healthy() = %v before losing the lease", err)
+			}
+
+			tt.lose(t, r, mock)
+			if r.Valid() {
+				t.Fatal("lease still valid")
+			}
+			if _, err := snowflake.NextID(); !errors.Is(err, errLeaseLost) {
+				t.Fatalf("NextID error = %v, want errLeaseLost", err)
+			}
+			if _, err := snowflake.NextN(10); !errors.Is(err, errLeaseLost) {
+				t.Fatalf("NextN error = %v, want errLeaseLost", err)
+			}
+			if err := snowflake.Healthy(); !errors.Is(err, errLeaseLost) {
+				t.Fatalf("Healthy() = %v, want errLeaseLost", err)
+			}
+		})
+	}
+}
+
+// 租约行中的时间戳高水位随机器 ID 转移给下一个节点
+func TestWorkerRegistryTimestampStore(t *testing.T) {
+	db, mock := newRegistryDB(t)
+	expectClaimed(mock, 1)
+	r, err := NewWorkerRegistry(db, 3, DefaultLayout.MaxMachine(), testLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mock.ExpectExec(releaseSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+		r.Close()
+	}()
+
+	mock.ExpectExec(saveSQL).WithArgs(1700000000000, 3, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := r.Save(context.Background(), 1700000000000); err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(loadSQL).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"last_timestamp"}).AddRow(1700000000000))
+	if ts, err := r.Load(); err != nil || ts != 1700000000000 {
+		t.Fatalf("Load() = %d, %v, want 1700000000000", ts, err)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mazezen/mid/proto/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+)
+
+// RESP 请求的大小限制，ID 相关命令的参数都很短
+const (
+	respMaxArgs    = 16
+	respMaxBulkLen = 4096
+)
+
+// respServer 兼容 Redis 协议（RESP2）的监听器，已有代码只需修改连接地址即可从 Redis INCR 迁移：
+//
+//	INCR <biz_tag>  返回该业务标识的下一个 segment ID
+//	SNOWFLAKE       返回一个 snowflake ID
+//
+// 另外支持 PING、ECHO、SELECT、CLIENT 和 QUIT，满足常见客户端库建立连接时的握手；
+// HELLO 按未知命令回复，客户端会退回 RESP2。请求直接调用 gRPC 服务的实现，ID 从相同的 Buffer 中取出
+type respServer struct {
+	srv *server
+	lis net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newRESPServer(srv *server) *respServer {
+	return &respServer{srv: srv, conns: make(map[net.Conn]struct{})}
+}
+
+// ListenAndServe 在 addr 上接受连接，直到 Close
+func (r *respServer) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(lis)
+}
+
+// Serve 在 lis 上接受连接，直到 Close
+func (r *respServer) Serve(lis net.Listener) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		lis.Close()
+		return net.ErrClosed
+	}
+	r.lis = lis
+	r.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			r.mu.Lock()
+			closed := r.closed
+			r.mu.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		r.conns[conn] = struct{}{}
+		r.wg.Add(1)
+		r.mu.Unlock()
+		go r.serveConn(conn)
+	}
+}
+
+// Shutdown 停止监听并断开所有连接，在 ctx 结束前等待进行中的命令完成
+func (r *respServer) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	if r.lis != nil {
+		r.lis.Close()
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *respServer) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		conn.Close()
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		r.wg.Done()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			var protoErr respProtocolError
+			if errors.As(err, &protoErr) {
+				writeRESPError(writer, "ERR Protocol error: "+protoErr.Error())
+				writer.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				mLog.Debug("RESP connection error", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := r.execute(ctx, writer, args)
+		// 管道中的后续命令已在缓冲区时暂不刷新，批量写回
+		if quit || reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute 执行一条命令并写入回复，返回是否关闭连接
+func (r *respServer) execute(ctx context.Context, w *bufio.Writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "INCR":
+		if len(args) != 2 {
+			writeRESPArityError(w, args[0])
+			return false
+		}
+		resp, err := r.srv.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "segment", BizTag: args[1]})
+		if err != nil {
+			writeRESPError(w, "ERR "+status.Convert(err).Message())
+			return false
+		}
+		writeRESPInteger(w, resp.Id)
+	case "SNOWFLAKE":
+		if len(args) != 1 {
+			writeRESPArityError(w, args[0])
+			return false
+		}
+		resp, err := r.srv.MakeIDService(ctx, &pb.MakeIDServiceRequest{Mode: "snowflake"})
+		if err != nil {
+			writeRESPError(w, "ERR "+status.Convert(err).Message())
+			return false
+		}
+		writeRESPInteger(w, resp.Id)
+	case "PING":
+		switch len(args) {
+		case 1:
+			w.WriteString("+PONG\r\n")
+		case 2:
+			writeRESPBulk(w, args[1])
+		default:
+			writeRESPArityError(w, args[0])
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			writeRESPArityError(w, args[0])
+			return false
+		}
+		writeRESPBulk(w, args[1])
+	case "SELECT", "CLIENT":
+		// 没有多个数据库，也不记录客户端信息
+		w.WriteString("+OK\r\n")
+	case "QUIT":
+		w.WriteString("+OK\r\n")
+		return true
+	default:
+		writeRESPError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// respProtocolError 请求不符合 RESP 格式，回复错误后断开连接
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return string(e)
+}
+
+// readRESPCommand 读取一条命令：RESP 数组形式的 bulk string，或者 redis-cli 使用的空格分隔的内联命令
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > respMaxArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%s'", line))
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > respMaxBulkLen {
+			return nil, respProtocolError("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, respProtocolError("bulk string is not terminated by CRLF")
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readRESPLine 读取一行并去掉行尾的 CRLF
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", respProtocolError("line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func writeRESPInteger(w *bufio.Writer, n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func writeRESPBulk(w *bufio.Writer, s string) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(s)))
+	w.WriteString("\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// writeRESPError 写入错误回复，消息中的换行会破坏协议，替换为空格
+func writeRESPError(w *bufio.Writer, msg string) {
+	w.WriteByte('-')
+	w.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(msg))
+	w.WriteString("\r\n")
+}
+
+func writeRESPArityError(w *bufio.Writer, name string) {
+	writeRESPError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRESPServer 启动监听器并返回监听地址和连接到它的 Redis 客户端
+func newTestRESPServer(t *testing.T) (*respServer, string, *redis.Client) {
+	t.Helper()
+	s := newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := newRESPServer(s)
+	go rs.Serve(lis)
+	t.Cleanup(func() { rs.Shutdown(context.Background()) })
+
+	rdb := redis.NewClient(&redis.Options{Addr: lis.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return rs, lis.Addr().String(), rdb
+}
+
+func TestRESPIncr(t *testing.T) {
+	_, _, rdb := newTestRESPServer(t)
+	ctx := context.Background()
+
+	var last int64
+	for i := 0; i < 250; i++ {
+		id, err := rdb.Incr(ctx, defaultBizTag).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("INCR returned %d after %d", id, last)
+		}
+		last = id
+	}
+
+	if err := rdb.Incr(ctx, "unknown").Err(); err == nil {
+		t.Fatal("INCR of an unknown biz_tag should fail")
+	}
+}
+
+func TestRESPSnowflake(t *testing.T) {
+	_, _, rdb := newTestRESPServer(t)
+	ctx := context.Background()
+
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		id, err := rdb.Do(ctx, "SNOWFLAKE").Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate snowflake ID %d", id)
+		}
+		seen[id] = true
+		if parts := DefaultLayout.Decode(id); parts.DatacenterID != 3 || parts.MachineID != 4 {
+			t.Fatalf("ID %d decodes to datacenter %d machine %d, want 3 and 4", id, parts.DatacenterID, parts.MachineID)
+		}
+	}
+
+	if err := rdb.Do(ctx, "SNOWFLAKE", "extra").Err(); err == nil || !strings.Contains(err.Error(), "wrong number of arguments") {
+		t.Fatalf("SNOWFLAKE with an argument: got %v, want arity error", err)
+	}
+}
+
+func TestRESPPipeline(t *testing.T) {
+	_, _, rdb := newTestRESPServer(t)
+	ctx := context.Background()
+
+	cmds, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := 0; i < 50; i++ {
+			pipe.Incr(ctx, defaultBizTag)
+		}
+		pipe.Ping(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	for _, cmd := range cmds[:50] {
+		id := cmd.(*redis.IntCmd).Val()
+		if id <= last {
+			t.Fatalf("pipelined INCR returned %d after %d", id, last)
+		}
+		last = id
+	}
+	if pong := cmds[50].(*redis.StatusCmd).Val(); pong != "PONG" {
+		t.Fatalf("PING = %q, want PONG", pong)
+	}
+}
+
+func TestRESPRawProtocol(t *testing.T) {
+	_, addr, _ := newTestRESPServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	roundTrip := func(request string) string {
+		t.Helper()
+		if _, err := conn.Write([]byte(request)); err != nil {
+			t.Fatal(err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("%q: %v", request, err)
+		}
+		return line
+	}
+
+	// redis-cli 风格的内联命令
+	if reply := roundTrip("incr default\r\n"); !strings.HasPrefix(reply, ":") {
+		t.Fatalf("inline INCR reply = %q, want an integer", reply)
+	}
+	if reply := roundTrip("FOO\r\n"); reply != "-ERR unknown command 'FOO'\r\n" {
+		t.Fatalf("unknown command reply = %q", reply)
+	}
+	// 格式错误时回复错误并断开连接
+	if reply := roundTrip("*1\r\n$99999\r\n"); !strings.HasPrefix(reply, "-ERR Protocol error") {
+		t.Fatalf("oversized bulk reply = %q, want protocol error", reply)
+	}
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Fatal("connection should be closed after a protocol error")
+	}
+}
+
+func TestRESPShutdownClosesConnections(t *testing.T) {
+	rs, _, rdb := newTestRESPServer(t)
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := rs.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Ping(ctx).Err(); err == nil {
+		t.Fatal("PING succeeded after shutdown")
+	}
+}
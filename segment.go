@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,71 +13,137 @@ import (
 	"go.uber.org/zap"
 )
 
-// Segment 结构体
+// defaultBizTag 请求未指定 biz_tag 时使用的业务标识
+const defaultBizTag = "default"
+
+// errBizTagNotFound id_segments 表中不存在该业务标识
+var errBizTagNotFound = errors.New("biz_tag not found")
+
+// errSegmentClosed 号段生成器已关闭
+var errSegmentClosed = errors.New("segment is closed")
+
+// errInvalidStep 业务标识配置的步长不是正数
+var errInvalidStep = errors.New("invalid step")
+
+// Segment 号段生成器，参考美团 Leaf 的双号段：当前号段消耗到 preloadPercent 时异步申请下一个号段，
+// 当前号段用尽时直接切换，只有下一个号段还没申请到时才需要等待存储后端
 type Segment struct {
-	db      *sql.DB
-	bizTag  string // 业务标识
-	current int64  // 当前ID
-	max     int64  // 当前段的最大 ID
-	step    int64  // 每次分配的 ID 段大小
+	alloc  segmentAllocator
+	bizTag string // 业务标识
+
+	ctx    context.Context // 号段申请使用，Close 时取消
+	cancel context.CancelFunc
+
 	mu      sync.Mutex
+	current segmentRange
+	next    *segmentRange // 已申请到的下一个号段
+	load    *segmentLoad  // 进行中的号段申请，同一时间最多一个
+	closed  bool
+	loads   sync.WaitGroup
+
+	preloadPercent int64 // 当前号段消耗到该百分比时申请下一个号段
+
+	// 动态步长：参考美团 Leaf，根据上一个号段的消耗时长调整下一次申请的步长
+	step      int64         // 最近一次申请的号段大小
+	baseStep  int64         // id_segments 表中配置的步长，也是步长下限
+	maxStep   int64         // 步长上限
+	target    time.Duration // 期望一个号段的消耗时长
+	lastFetch time.Time     // 上一次申请号段的时间
 }
 
-func NewSegment(dsn string) (*Segment, error) {
-	db, err := sql.Open("mysql", dsn)
+// segmentRange 号段 [start, max]，next 为下一个要发放的 ID
+type segmentRange struct {
+	start, next, max int64
+}
+
+func (r *segmentRange) remaining() int64 {
+	return r.max - r.next + 1
+}
+
+// segmentLoad 一次号段申请，完成后关闭 done
+type segmentLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// openMySQL 连接 MySQL，所有业务标识共享同一个连接池
+func openMySQL(cfg MySQLConfig) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN)
 	if err != nil {
 		mLog.Error("Failed to connect to MySQL", zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to mysql: %v", err)
 	}
 	if err := db.Ping(); err != nil {
 		mLog.Error("Failed to ping MySQL", zap.Error(err))
+		db.Close()
 		return nil, fmt.Errorf("failed to ping MySQL: %v", err)
 	}
-	db.SetMaxOpenConns(10000)
-	db.SetConnMaxIdleTime(500)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// NewSegment 为 bizTag 创建号段生成器，bizTag 必须已存在于 id_segments 表中，基础步长取自该行的 step 列。
+// ctx 结束后进行中的号段申请被取消
+func NewSegment(ctx context.Context, alloc segmentAllocator, bizTag string, cfg SegmentConfig) (*Segment, error) {
+	step, err := alloc.Step(ctx, bizTag)
+	if errors.Is(err, errBizTagNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		mLog.Error("Failed to query biz_tag",
+			zap.String("biz_tag", bizTag),
+			zap.String("backend", alloc.Backend()),
+			zap.Error(err))
+		return nil, err
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("%w %d for biz_tag %s", errInvalidStep, step, bizTag)
+	}
+	ctx, cancel := context.WithCancel(ctx)
 	return &Segment{
-		db:     db,
-		bizTag: "default",
-		step:   10000, // 每次分配 10000 个 ID
+		alloc:          alloc,
+		bizTag:         bizTag,
+		ctx:            ctx,
+		cancel:         cancel,
+		current:        segmentRange{start: 1, next: 1, max: 0}, // 空号段，首次 NextID 时申请
+		preloadPercent: int64(cfg.PreloadPercent),
+		step:           step,
+		baseStep:       step,
+		maxStep:        max(cfg.MaxStep, step),
+		target:         cfg.TargetDuration,
 	}, nil
 }
 
-func (s *Segment) fetchNewSegment() (int64, error) {
+// nextStep 根据上一个号段的消耗时长计算下一次申请的步长：
+// 短于 target 时翻倍（不超过 maxStep），长于 2 倍 target 时减半（不低于 baseStep），否则不变
+func (s *Segment) nextStep(now time.Time) int64 {
+	if s.lastFetch.IsZero() {
+		return s.step
+	}
+	elapsed := now.Sub(s.lastFetch)
+	switch {
+	case elapsed < s.target:
+		return min(s.step*2, s.maxStep)
+	case elapsed >= 2*s.target:
+		return max(s.step/2, s.baseStep)
+	default:
+		return s.step
+	}
+}
+
+// fetchNewSegment 从存储后端申请大小为 step 的号段，失败时指数退避重试，Close 后立即返回
+func (s *Segment) fetchNewSegment(step int64) (int64, error) {
 	var newMax int64
 	startTime := time.Now()
 	operation := func() error {
-		tx, err := s.db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to start transaction: %v", err)
+		var err error
+		newMax, err = s.alloc.Reserve(s.ctx, s.bizTag, step)
+		if errors.Is(err, errBizTagNotFound) {
+			// 业务标识被删除，重试没有意义
+			return backoff.Permanent(err)
 		}
-		defer tx.Rollback()
-
-		// 原子更新 max_id
-		result, err := tx.Exec(
-			"UPDATE id_segments SET max_id = max_id + ? WHERE biz_tag = ?",
-			s.step, s.bizTag,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to update max_id: %v", err)
-		}
-		rowsAffected, err := result.RowsAffected()
-		if err != nil || rowsAffected != 1 {
-			return fmt.Errorf("failed to update id_segments")
-		}
-
-		// 获取新的 max_id
-		err = tx.QueryRow(
-			"SELECT max_id FROM id_segments WHERE biz_tag = ?",
-			s.bizTag,
-		).Scan(&newMax)
-		if err != nil {
-			return fmt.Errorf("failed to query max_id: %v", err)
-		}
-
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction: %v", err)
-		}
-		return nil
+		return err
 	}
 
 	// 配置指数退避重试
@@ -87,67 +155,136 @@ func (s *Segment) fetchNewSegment() (int64, error) {
 	err := backoff.Retry(func() error {
 		err := operation()
 		if err != nil {
-			mLog.Warn("Retrying MySQL operation", zap.Error(err))
+			mLog.Warn("Retrying segment allocation",
+				zap.String("backend", s.alloc.Backend()),
+				zap.Error(err))
 		}
 		return err
-	}, b)
+	}, backoff.WithContext(b, s.ctx))
 	if err != nil {
-		mLog.Error("Failed to fetch new segment after retries", zap.Error(err))
+		mLog.Error("Failed to fetch new segment after retries",
+			zap.String("biz_tag", s.bizTag),
+			zap.Error(err))
 		return 0, err
 	}
 
 	duration := time.Since(startTime).Seconds()
-	mysqlQueryDuration.Observe(duration)
+	segmentReserveDuration.WithLabelValues(s.alloc.Backend()).Observe(duration)
+	segmentStepGauge.WithLabelValues(s.bizTag).Set(float64(step))
 	mLog.Info("Fetched new segment",
+		zap.String("biz_tag", s.bizTag),
 		zap.Int64("new_max", newMax),
+		zap.Int64("step", step),
 		zap.Float64("duration_seconds", duration))
 	return newMax, nil
 }
 
-func (s *Segment) StartPreload() {
-	go func () {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C { 
-			s.mu.Lock()
-			if s.current+5000 >= s.max { // 剩余 ID 少于 50% 时预加载
-				newMax, err := s.fetchNewSegment()
-				if err != nil {
-					mLog.Error("Failed to preload segment", zap.Error(err))
-					s.mu.Unlock()
-					continue
-				}
-				s.current = newMax - s.step + 1
-				s.max = newMax
-			}
-			s.mu.Unlock()
+// startLoad 异步申请下一个号段，已有申请在进行时直接返回它，调用方需持有 s.mu
+func (s *Segment) startLoad() *segmentLoad {
+	if s.load != nil {
+		return s.load
+	}
+	now := time.Now()
+	step := s.nextStep(now)
+	if step != s.step {
+		mLog.Info("Adjusted segment step",
+			zap.String("biz_tag", s.bizTag),
+			zap.Int64("old_step", s.step),
+			zap.Int64("new_step", step),
+			zap.Duration("since_last_fetch", now.Sub(s.lastFetch)))
+	}
+	s.step = step
+	s.lastFetch = now
+
+	l := &segmentLoad{done: make(chan struct{})}
+	s.load = l
+	s.loads.Add(1)
+	go func() {
+		defer s.loads.Done()
+		newMax, err := s.fetchNewSegment(step)
+
+		s.mu.Lock()
+		if err == nil {
+			s.next = &segmentRange{start: newMax - step + 1, next: newMax - step + 1, max: newMax}
+		} else {
+			l.err = err
 		}
+		s.load = nil
+		s.mu.Unlock()
+		close(l.done)
 	}()
+	return l
 }
 
 // NextID 获取下一个 ID
 func (s *Segment) NextID() (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for {
+		if s.closed {
+			return 0, errSegmentClosed
+		}
 
-	// 当前段有可用 ID
-	if s.current < s.max {
-		s.current++
-		return s.current, nil
+		// 当前段有可用 ID
+		if s.current.next <= s.current.max {
+			id := s.current.next
+			s.current.next++
+			if s.next == nil && s.load == nil {
+				size := s.current.max - s.current.start + 1
+				if (s.current.next-s.current.start)*100 >= size*s.preloadPercent {
+					s.startLoad()
+				}
+			}
+			return id, nil
+		}
+
+		// 当前段用尽，切换到已申请好的下一个号段
+		if s.next != nil {
+			s.current, s.next = *s.next, nil
+			continue
+		}
+
+		// 下一个号段还没申请到（首次使用或预加载失败），等待申请完成
+		l := s.startLoad()
+		s.mu.Unlock()
+		<-l.done
+		s.mu.Lock()
+		if l.err != nil {
+			return 0, l.err
+		}
 	}
+}
 
-	// 获取新 ID 段
-	newMax, err := s.fetchNewSegment()
-	if err != nil {
-		return 0, err
+// NextN 依次取出 n 个 ID，用于填充 Buffer
+func (s *Segment) NextN(n int) ([]int64, error) {
+	ids := make([]int64, n)
+	for i := range ids {
+		id, err := s.NextID()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
 	}
+	return ids, nil
+}
 
-	s.current = newMax - s.step + 1
-	s.max = newMax
-	return s.current, nil
+// Remaining 当前号段和已申请的下一个号段中尚未发放的 ID 数量
+func (s *Segment) Remaining() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := s.current.remaining()
+	if s.next != nil {
+		remaining += s.next.remaining()
+	}
+	return remaining
 }
 
-// Close 关闭 MySQL 连接
+// Close 取消并等待进行中的号段申请，之后不再发号；存储后端由调用方统一关闭
 func (s *Segment) Close() error {
-	return s.db.Close()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+	s.loads.Wait()
+	return nil
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestSegment(t testing.TB, alloc segmentAllocator) *Segment {
+	t.Helper()
+	mLog = zap.NewNop()
+	segment, err := NewSegment(context.Background(), alloc, defaultBizTag, SegmentConfig{TargetDuration: 15 * time.Minute, MaxStep: 1000000, PreloadPercent: 10})
+	if err != nil {
+		t.Fatalf("NewSegment: %v", err)
+	}
+	t.Cleanup(func() { segment.Close() })
+	return segment
+}
+
+func TestSegmentBizTagNotFound(t *testing.T) {
+	mLog = zap.NewNop()
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 10})
+	if _, err := NewSegment(context.Background(), alloc, "missing", SegmentConfig{TargetDuration: time.Minute, MaxStep: 10, PreloadPercent: 10}); !errors.Is(err, errBizTagNotFound) {
+		t.Fatalf("NewSegment(missing) error = %v, want errBizTagNotFound", err)
+	}
+}
+
+func TestSegmentRetriesBackendFailures(t *testing.T) {
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 10})
+	segment := newTestSegment(t, alloc)
+
+	// 前两次分配失败，退避重试后成功
+	alloc.FailNext(2, nil)
+	id, err := segment.NextID()
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	if id != 1 {
+		t.Fatalf("NextID = %d, want 1", id)
+	}
+	if got := alloc.MaxID(defaultBizTag); got != 10 {
+		t.Fatalf("max_id = %d, want 10", got)
+	}
+}
+
+func TestSegmentPreloadsNextRange(t *testing.T) {
+	mLog = zap.NewNop()
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 100})
+	const latency = 100 * time.Millisecond
+	alloc.SetLatency(latency)
+	// target 极短，步长保持为基础步长 100
+	segment, err := NewSegment(context.Background(), alloc, defaultBizTag, SegmentConfig{TargetDuration: time.Nanosecond, MaxStep: 100, PreloadPercent: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer segment.Close()
+
+	// 首次使用需要等待申请号段
+	if id, err := segment.NextID(); err != nil || id != 1 {
+		t.Fatalf("NextID = %d, %v; want 1", id, err)
+	}
+	// 消耗到 50% 后开始预加载下一个号段
+	for want := int64(2); want <= 50; want++ {
+		if id, _ := segment.NextID(); id != want {
+			t.Fatalf("NextID = %d, want %d", id, want)
+		}
+	}
+	if got := alloc.MaxID(defaultBizTag); got != 100 {
+		t.Fatalf("max_id = %d before preload finished, want 100", got)
+	}
+	time.Sleep(2 * latency)
+	if got := alloc.MaxID(defaultBizTag); got != 200 {
+		t.Fatalf("max_id = %d after preload, want 200", got)
+	}
+
+	// 切换到预加载的号段不等待存储后端
+	start := time.Now()
+	for want := int64(51); want <= 120; want++ {
+		if id, _ := segment.NextID(); id != want {
+			t.Fatalf("NextID = %d, want %d", id, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= latency {
+		t.Fatalf("switching to the preloaded range took %v", elapsed)
+	}
+	if remaining := segment.Remaining(); remaining != 80 {
+		t.Fatalf("Remaining = %d, want 80", remaining)
+	}
+}
+
+func TestSegmentNextStep(t *testing.T) {
+	mLog = zap.NewNop()
+	const target = time.Minute
+	now := time.Now()
+	tests := []struct {
+		name     string
+		maxStep  int64 // 配置的步长上限，基础步长为 100
+		step     int64
+		elapsed  time.Duration // 距上一次申请的时长，0 表示首次申请
+		wantStep int64
+	}{
+		{"first fetch", 10000, 100, 0, 100},
+		{"fast doubles", 10000, 400, target / 2, 800},
+		{"fast capped at max_step", 10000, 8000, target / 2, 10000},
+		{"at max_step stays", 10000, 10000, time.Second, 10000},
+		{"max_step below base step", 50, 100, time.Second, 100},
+		{"within target keeps", 10000, 400, target, 400},
+		{"just under twice target keeps", 10000, 400, 2*target - time.Millisecond, 400},
+		{"slow halves", 10000, 400, 2 * target, 200},
+		{"slow floored at base step", 10000, 150, time.Hour, 100},
+		{"base step stays", 10000, 100, time.Hour, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 100})
+			segment, err := NewSegment(context.Background(), alloc, defaultBizTag, SegmentConfig{TargetDuration: target, MaxStep: tt.maxStep, PreloadPercent: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer segment.Close()
+			segment.step = tt.step
+			if tt.elapsed > 0 {
+				segment.lastFetch = now.Add(-tt.elapsed)
+			}
+			if got := segment.nextStep(now); got != tt.wantStep {
+				t.Fatalf("nextStep = %d, want %d", got, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestSegmentConcurrentNextIDUnique(t *testing.T) {
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 100})
+	alloc.SetLatency(time.Millisecond)
+	segment := newTestSegment(t, alloc)
+
+	const workers, perWorker = 16, 500
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool, workers*perWorker)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				id, err := segment.NextID()
+				if err != nil {
+					t.Errorf("NextID: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*perWorker {
+		t.Fatalf("got %d ids, want %d", len(seen), workers*perWorker)
+	}
+}
+
+func TestMemoryAllocatorLatencyHonoursContext(t *testing.T) {
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 10})
+	alloc.SetLatency(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := alloc.Reserve(ctx, defaultBizTag, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Reserve error = %v, want DeadlineExceeded", err)
+	}
+	if got := alloc.MaxID(defaultBizTag); got != 0 {
+		t.Fatalf("max_id = %d after cancelled Reserve, want 0", got)
+	}
+}
+
+func BenchmarkSegmentNextID(b *testing.B) {
+	segment := newTestSegment(b, newMemoryAllocator(map[string]int64{defaultBizTag: 10000}))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := segment.NextID(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/mazezen/mid/proto/pb"
+	pbv2 "github.com/mazezen/mid/proto/pb/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
 )
 
 // Prometheus 指标
@@ -17,14 +21,14 @@ var (
 			Name: "id_generate_total",
 			Help: "Total number of IDs generated",
 		},
-		[]string{"mode"},
+		[]string{"mode", "biz_tag"},
 	)
 	bufferUsageGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "buffer_usage",
 			Help: "Number of remaining IDs in buffer",
 		},
-		[]string{"mode", "buffer"},
+		[]string{"mode", "biz_tag"},
 	)
 	mysqlQueryDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
@@ -33,6 +37,21 @@ var (
 			Buckets: prometheus.DefBuckets,
 		},
 	)
+	segmentReserveDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "segment_reserve_duration_seconds",
+			Help:    "Duration of reserving a new segment from the storage backend, including retries",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"backend"},
+	)
+	segmentStepGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "segment_step",
+			Help: "Step of the most recently fetched segment",
+		},
+		[]string{"biz_tag"},
+	)
 	ntpOffsetGauge = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "ntp_offset_milliseconds",
@@ -42,142 +61,403 @@ var (
 )
 
 func init() {
-	prometheus.MustRegister(idGenerateCounter, bufferUsageGauge, mysqlQueryDuration, ntpOffsetGauge)
+	prometheus.MustRegister(idGenerateCounter, bufferUsageGauge, mysqlQueryDuration, segmentReserveDuration, segmentStepGauge, ntpOffsetGauge)
 }
-// IDBuffer 管理预生成的 ID 段
-type IDBuffer struct {
-	ids       []int64
-	index     int
-	size      int
-	threshold int // 触发异步填充的阈值
+
+// segmentSlot 懒加载的业务标识号段及其 Buffer。
+// 创建结果在 segmentsMu 下写入并置 ready，其他协程持有 segmentsMu 时只读取已就绪的 slot
+type segmentSlot struct {
+	once    sync.Once
+	ready   bool
+	segment *Segment
+	buffer  *IDBuffer[int64]
+	err     error
 }
 
 type server struct {
 	pb.UnimplementedIDMakerServer
-	snowflake *Snowflake
-	segment *Segment
-	snowfalkeBuffers *struct {
-		buffer1   *IDBuffer
-		buffer2   *IDBuffer
-		m1     sync.Mutex // buffer1 专用锁
-        m2     sync.Mutex // buffer2 专用锁
+	snowflake       *Snowflake
+	alloc           segmentAllocator // 号段存储后端，为 nil 时 segment 模式不可用
+	snowflakeBuffer *IDBuffer[int64]
+	ulid            *SortableGenerator
+	ulidBuffer      *IDBuffer[[16]byte]
+	uuidv7          *SortableGenerator
+	uuidv7Buffer    *IDBuffer[[16]byte]
+	segmentsMu      sync.Mutex
+	segments        map[string]*segmentSlot // 按 biz_tag 懒加载
+	segmentCfg      SegmentConfig
+	bufferCfg       BufferConfig
+	ctx             context.Context // 号段创建和申请使用，Close 时取消
+	cancel          context.CancelFunc
+	draining        chan struct{} // 关闭后进行中的 StreamIDs 立即结束
+	drainOnce       sync.Once
+}
+
+func newServer(snowflake *Snowflake, alloc segmentAllocator, segmentCfg SegmentConfig, bufferCfg BufferConfig) *server {
+	ulid := NewSortableGenerator(kindULID)
+	uuidv7 := NewSortableGenerator(kindUUIDv7)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &server{
+		snowflake:       snowflake,
+		alloc:           alloc,
+		snowflakeBuffer: NewIDBuffer("snowflake", "", snowflake.NextN, bufferCfg.Size, bufferCfg.LowWater),
+		ulid:            ulid,
+		ulidBuffer:      NewIDBuffer("ulid", "", ulid.NextN, bufferCfg.Size, bufferCfg.LowWater),
+		uuidv7:          uuidv7,
+		uuidv7Buffer:    NewIDBuffer("uuidv7", "", uuidv7.NextN, bufferCfg.Size, bufferCfg.LowWater),
+		segments:        make(map[string]*segmentSlot),
+		segmentCfg:      segmentCfg,
+		bufferCfg:       bufferCfg,
+		ctx:             ctx,
+		cancel:          cancel,
+		draining:        make(chan struct{}),
+	}
+}
+
+// segmentBuffer 返回 bizTag 对应的 Buffer，首次访问时创建号段并开始填充
+func (s *server) segmentBuffer(bizTag string) (*IDBuffer[int64], error) {
+	if s.alloc == nil {
+		return nil, segmentDisabledError()
 	}
-	segmentBuffers *struct {
-		buffer1   *IDBuffer
-		buffer2   *IDBuffer
-		m1     sync.Mutex // buffer1 专用锁
-        m2     sync.Mutex // buffer2 专用锁
+
+	s.segmentsMu.Lock()
+	slot, ok := s.segments[bizTag]
+	if !ok {
+		slot = &segmentSlot{}
+		s.segments[bizTag] = slot
 	}
+	s.segmentsMu.Unlock()
+
+	slot.once.Do(func() {
+		segment, buffer, err := s.newSegmentBuffer(bizTag)
+		s.segmentsMu.Lock()
+		slot.segment, slot.buffer, slot.err = segment, buffer, err
+		slot.ready = true
+		s.segmentsMu.Unlock()
+	})
+	if slot.err != nil {
+		// 创建失败时移除，后续请求可以重试（例如业务标识稍后才写入表中）
+		s.segmentsMu.Lock()
+		if s.segments[bizTag] == slot {
+			delete(s.segments, bizTag)
+		}
+		s.segmentsMu.Unlock()
+		return nil, generateError(slot.err, "segment", bizTag)
+	}
+	return slot.buffer, nil
 }
 
-func NewIDBuffer(size, threshold int) *IDBuffer {
-	return &IDBuffer{
-		ids:       make([]int64, size),
-		index:     0,
-		size:      size,
-		threshold: threshold,
+func (s *server) newSegmentBuffer(bizTag string) (*Segment, *IDBuffer[int64], error) {
+	segment, err := NewSegment(s.ctx, s.alloc, bizTag, s.segmentCfg)
+	if err != nil {
+		return nil, nil, err
 	}
+	buffer := NewIDBuffer("segment", bizTag, segment.NextN, s.bufferCfg.Size, s.bufferCfg.LowWater)
+	mLog.Info("Segment created", zap.String("biz_tag", bizTag))
+	return segment, buffer, nil
 }
 
-// 填充 Buffer
-func (s *server) fillBuffer(buffer *IDBuffer, mode string) error {
-	ids := make([]int64, buffer.size)
-	for i := 0; i < buffer.size; i++ {
-		var id int64
-		var err error
-		if mode == "snowflake" {
-			id, err = s.snowflake.NextID()
-		} 
-		if mode == "segment" {
-			id, err = s.segment.NextID()
+// bufferFor 根据生成器类型和业务标识选择 64 位 ID 的 Buffer
+func (s *server) bufferFor(generator pbv2.Generator, bizTag string) (*IDBuffer[int64], error) {
+	switch generator {
+	case pbv2.Generator_GENERATOR_SNOWFLAKE:
+		return s.snowflakeBuffer, nil
+	case pbv2.Generator_GENERATOR_SEGMENT:
+		if bizTag == "" {
+			bizTag = defaultBizTag
 		}
-		if err != nil {
-			return err
+		return s.segmentBuffer(bizTag)
+	default:
+		return nil, invalidArgument("generator", "invalid generator %v, must be GENERATOR_SNOWFLAKE or GENERATOR_SEGMENT", generator)
+	}
+}
+
+// generatorFromMode 将 v1 接口的 mode 字符串转换为 v2 的生成器类型
+func generatorFromMode(mode string) (pbv2.Generator, error) {
+	switch mode {
+	case "snowflake":
+		return pbv2.Generator_GENERATOR_SNOWFLAKE, nil
+	case "segment":
+		return pbv2.Generator_GENERATOR_SEGMENT, nil
+	}
+	return pbv2.Generator_GENERATOR_UNSPECIFIED, invalidArgument("mode", "invalid mode %q, must be 'snowflake' or 'segment'", mode)
+}
+
+// v1Request 将 v1 接口的请求转换为 v2 的 GenerateRequest
+func v1Request(mode, bizTag string, count int32) (*pbv2.GenerateRequest, error) {
+	generator, err := generatorFromMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	req := &pbv2.GenerateRequest{Generator: generator, Count: count}
+	if generator == pbv2.Generator_GENERATOR_SEGMENT {
+		req.Options = &pbv2.GenerateRequest_Segment{Segment: &pbv2.SegmentOptions{BizTag: bizTag}}
+	}
+	return req, nil
+}
+
+// Shutdown 停止所有 Buffer 的填充并等待进行中的填充结束，记录被丢弃的预取 ID 并停止号段预加载。
+// ctx 结束时取消进行中的号段申请并立即返回
+func (s *server) Shutdown(ctx context.Context) error {
+	s.Drain()
+
+	s.segmentsMu.Lock()
+	slots := make([]*segmentSlot, 0, len(s.segments))
+	for _, slot := range s.segments {
+		if slot.ready && slot.buffer != nil {
+			slots = append(slots, slot)
 		}
+	}
+	s.segmentsMu.Unlock()
 
-		ids[i] = id
+	buffers := []idBuffer{s.snowflakeBuffer, s.ulidBuffer, s.uuidv7Buffer}
+	for _, slot := range slots {
+		buffers = append(buffers, slot.buffer)
+	}
+	for _, buffer := range buffers {
+		buffer.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, buffer := range buffers {
+			buffer.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// 取消进行中的号段申请，存储后端不响应时也不再等待
+		s.Close(ctx)
+		return fmt.Errorf("waiting for buffer fills: %v", ctx.Err())
+	}
+
+	logDiscarded(s.snowflakeBuffer, nil)
+	logDiscarded(s.ulidBuffer, nil)
+	logDiscarded(s.uuidv7Buffer, nil)
+	for _, slot := range slots {
+		logDiscarded(slot.buffer, slot.segment)
+	}
+	if err := s.Close(ctx); err != nil {
+		return fmt.Errorf("waiting for segment loads: %v", err)
 	}
-	copy(buffer.ids, ids)
-	buffer.index = 0
-	mLog.Info("Buffer filled",
-		zap.String("mode", mode),
-		zap.Int("size", buffer.size))
 	return nil
 }
 
-// MakeIDService gRPC 服务实现
+// Drain 通知进行中的 StreamIDs 以 SHUTTING_DOWN 结束，需在 GracefulStop 之前调用，
+// 否则 GracefulStop 要等到客户端主动取消流
+func (s *server) Drain() {
+	s.drainOnce.Do(func() { close(s.draining) })
+}
+
+// logDiscarded 记录关闭时 Buffer 和号段中尚未发放的 ID 数量
+func logDiscarded(buffer idBuffer, segment *Segment) {
+	mode, bizTag := buffer.labels()
+	fields := []zap.Field{
+		zap.String("mode", mode),
+		zap.String("biz_tag", bizTag),
+		zap.Int("buffered", buffer.Len()),
+	}
+	if segment != nil {
+		fields = append(fields, zap.Int64("segment_remaining", segment.Remaining()))
+	}
+	mLog.Info("Discarded pre-fetched IDs", fields...)
+}
+
+// segmentBuffered 所有业务标识的 Buffer 中尚未发放的 ID 总数
+func (s *server) segmentBuffered() int {
+	s.segmentsMu.Lock()
+	buffers := make([]*IDBuffer[int64], 0, len(s.segments))
+	for _, slot := range s.segments {
+		if slot.ready && slot.buffer != nil {
+			buffers = append(buffers, slot.buffer)
+		}
+	}
+	s.segmentsMu.Unlock()
+
+	total := 0
+	for _, buffer := range buffers {
+		total += buffer.Len()
+	}
+	return total
+}
+
+// Close 取消进行中的号段申请并停止所有号段的预加载，最多等待到 ctx 结束
+func (s *server) Close(ctx context.Context) error {
+	s.cancel()
+
+	s.segmentsMu.Lock()
+	segments := make([]*Segment, 0, len(s.segments))
+	for _, slot := range s.segments {
+		if slot.ready && slot.segment != nil {
+			segments = append(segments, slot.segment)
+		}
+	}
+	s.segmentsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, segment := range segments {
+			segment.Close()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MakeIDService gRPC 服务实现，转换为 v2 请求处理
 func (s *server) MakeIDService(ctx context.Context, req *pb.MakeIDServiceRequest) (*pb.MakeIDServiceResponse, error) {
-	mode := req.Mode
-	if mode != "snowflake" && mode != "segment" {
-		mLog.Error("Invalid mode",
-			zap.String("mode", mode))
-		return nil, fmt.Errorf("invalid mode: %s, must be 'snowflake' or 'segment'", mode)
-	}
-
-	var buffers *struct {
-        buffer1 *IDBuffer
-        buffer2 *IDBuffer
-        m1     sync.Mutex
-        m2     sync.Mutex
-    }
-	if mode == "snowflake" {
-		buffers = s.snowfalkeBuffers
-	} else {
-		buffers = s.segmentBuffers
-	}
-
-	// 从 buffer1 获取 ID
-	buffers.m1.Lock()
-	if buffers.buffer1.index < buffers.buffer1.size {
-		id := buffers.buffer1.ids[buffers.buffer1.index]
-		buffers.buffer1.index++
-		idGenerateCounter.WithLabelValues(mode).Inc()
-		bufferUsageGauge.WithLabelValues(mode, "buffer1").Set(float64(buffers.buffer1.size - buffers.buffer1.index))
-		bufferUsageGauge.WithLabelValues(mode, "buffer2").Set(float64(buffers.buffer2.size - buffers.buffer2.index))
-
-		// 达到阈值且 buffer2 为空，异步填充 buffer2
-		if buffers.buffer1.index >= buffers.buffer1.threshold && buffers.buffer2.index >= buffers.buffer2.size {
-			go func() {
-				buffers.m2.Lock()
-				defer buffers.m2.Unlock()
-				if err := s.fillBuffer(buffers.buffer2, mode); err != nil {
-					mLog.Error("failed to fill buffer2", zap.String("mode", mode), zap.Error(err))
-				}
-			}()
-		}
-		buffers.m1.Unlock()
-		return &pb.MakeIDServiceResponse{Id: id}, nil
-	}
-	buffers.m1.Unlock()
-
-	// buffer1 用尽，切换到 buffer2
-	buffers.m2.Lock()
-	if buffers.buffer2.index < buffers.buffer2.size {
-		buffers.m1.Lock()
-		buffers.buffer1, buffers.buffer2 = buffers.buffer2, buffers.buffer1
-		buffers.buffer2 = NewIDBuffer(1000, 500)
-		buffers.m1.Unlock()
-		go func () {
-			buffers.m2.Lock()
-			defer buffers.m2.Unlock()
-			if err := s.fillBuffer(buffers.buffer2, mode); err != nil {
-				mLog.Error("failed to fill buffer2", zap.String("mode", mode), zap.Error(err))
-			}
-		}()
-		buffers.m2.Unlock()
-		return s.MakeIDService(ctx, req)
-	}
-	buffers.m2.Unlock()
-
-	// 两个 Buffer 都用尽，同步填充 buffer1
-	buffers.m1.Lock()
-	if err := s.fillBuffer(buffers.buffer1, mode); err != nil {
-		mLog.Error("Failed to fill buffer1",
-			zap.String("mode", mode),
-			zap.Error(err))
-			buffers.m1.Unlock()
+	v2Req, err := v1Request(req.Mode, req.BizTag, 1)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.generate(ctx, v2Req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MakeIDServiceResponse{Id: resp.Ids[0]}, nil
+}
+
+// MakeIDBatch 一次返回多个 ID，减少批量场景下的往返次数
+func (s *server) MakeIDBatch(ctx context.Context, req *pb.MakeIDBatchRequest) (*pb.MakeIDBatchResponse, error) {
+	if req.Count <= 0 || int(req.Count) > s.bufferCfg.MaxBatchSize {
+		return nil, invalidArgument("count", "count must be between 1 and %d", s.bufferCfg.MaxBatchSize)
+	}
+	v2Req, err := v1Request(req.Mode, req.BizTag, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.generate(ctx, v2Req)
+	if err != nil {
 		return nil, err
 	}
-	buffers.m1.Unlock()
-	return s.MakeIDService(ctx, req)
+	return &pb.MakeIDBatchResponse{Ids: resp.Ids}, nil
+}
+
+// StreamIDs 按 chunk 持续推送 ID，直到客户端取消
+func (s *server) StreamIDs(req *pb.StreamIDsRequest, stream pb.IDMaker_StreamIDsServer) error {
+	if req.ChunkSize <= 0 || int(req.ChunkSize) > s.bufferCfg.MaxBatchSize {
+		return invalidArgument("chunk_size", "chunk_size must be between 1 and %d", s.bufferCfg.MaxBatchSize)
+	}
+	if req.IdsPerSecond < 0 {
+		return invalidArgument("ids_per_second", "ids_per_second must not be negative")
+	}
+	generator, err := generatorFromMode(req.Mode)
+	if err != nil {
+		return err
+	}
+	buffer, err := s.bufferFor(generator, req.BizTag)
+	if err != nil {
+		return err
+	}
+
+	// 按速率计算两次推送之间的间隔
+	var throttle <-chan time.Time
+	if req.IdsPerSecond > 0 {
+		interval := time.Duration(int64(time.Second) * int64(req.ChunkSize) / int64(req.IdsPerSecond))
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			throttle = ticker.C
+		}
+	}
+
+	// 服务关闭时取消等待中的 Take
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.draining:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	mode, bizTag := buffer.labels()
+	shuttingDown := func() error {
+		select {
+		case <-s.draining:
+			return generateError(errBufferClosed, mode, bizTag)
+		default:
+			return nil
+		}
+	}
+
+	for {
+		if err := shuttingDown(); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		ids, err := nextIDs(ctx, buffer, int(req.ChunkSize), s.bufferCfg.WaitTimeout)
+		if err != nil {
+			if err := shuttingDown(); err != nil {
+				return err
+			}
+			return err
+		}
+		// Send 在流控窗口耗尽时阻塞，慢消费者不会提前从 Buffer 中取走更多 ID
+		if err := stream.Send(&pb.IDChunk{Ids: ids}); err != nil {
+			return err
+		}
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+			case <-throttle:
+			}
+		}
+	}
+}
+
+// DecodeID 按当前节点的位布局拆解 Snowflake ID
+func (s *server) DecodeID(ctx context.Context, req *pb.DecodeIDRequest) (*pb.DecodeIDResponse, error) {
+	layout := DefaultLayout
+	if s.snowflake != nil {
+		layout = s.snowflake.Layout()
+	}
+	parts := layout.Decode(req.Id)
+	return &pb.DecodeIDResponse{
+		Timestamp:    parts.Timestamp,
+		DatacenterId: parts.DatacenterID,
+		MachineId:    parts.MachineID,
+		Sequence:     parts.Sequence,
+		Valid:        parts.Valid,
+		Reason:       parts.Reason,
+	}, nil
+}
+
+// nextIDs 从 Buffer 中取出 n 个 ID，Buffer 为空时最多等待 waitTimeout（buffer.wait_timeout）
+func nextIDs[T any](ctx context.Context, buffer *IDBuffer[T], n int, waitTimeout time.Duration) ([]T, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	ids := make([]T, 0, n)
+	for len(ids) < n {
+		got, err := buffer.Take(waitCtx, n-len(ids))
+		if err != nil {
+			return nil, bufferError(ctx, buffer, err)
+		}
+		ids = append(ids, got...)
+	}
+	return ids, nil
+}
+
+// bufferError 将等待 Buffer 时的错误转换为 gRPC 状态，ctx 为请求本身的上下文
+func bufferError(ctx context.Context, buffer idBuffer, err error) error {
+	mode, bizTag := buffer.labels()
+	switch {
+	case errors.Is(err, errBufferClosed):
+		return generateError(err, mode, bizTag)
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return bufferEmptyError(mode, bizTag)
+	}
+	// 填充失败，例如存储后端不可达、时钟回退
+	return generateError(err, mode, bizTag)
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer 创建使用 alloc 作为号段存储后端的服务，alloc 为 nil 时不启用 segment 模式。
+// snowflake 的数据中心 ID 为 3、机器 ID 为 4，测试结束时关闭
+func newTestServer(t *testing.T, alloc segmentAllocator, waitTimeout time.Duration) *server {
+	t.Helper()
+	snowflake, err := NewSnowflake(3, 4, DefaultLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServerWith(t, snowflake, alloc, waitTimeout)
+}
+
+// newTestServerWith 同 newTestServer，使用调用方创建的 snowflake
+func newTestServerWith(t *testing.T, snowflake *Snowflake, alloc segmentAllocator, waitTimeout time.Duration) *server {
+	t.Helper()
+	mLog = zap.NewNop()
+	s := newServer(snowflake, alloc,
+		SegmentConfig{TargetDuration: 15 * time.Minute, MaxStep: 1000000, PreloadPercent: 10},
+		BufferConfig{Size: 100, LowWater: 50, WaitTimeout: waitTimeout, MaxBatchSize: 1000})
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+func TestServerSegmentBatchWithMemoryBackend(t *testing.T) {
+	s := newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second)
+
+	var last int64
+	for i := 0; i < 10; i++ {
+		resp, err := s.MakeIDBatch(context.Background(), &pb.MakeIDBatchRequest{Mode: "segment", Count: 150})
+		if err != nil {
+			t.Fatalf("MakeIDBatch: %v", err)
+		}
+		for _, id := range resp.Ids {
+			if id <= last {
+				t.Fatalf("id %d not greater than previous %d", id, last)
+			}
+			last = id
+		}
+	}
+}
+
+// 健康检查统计缓冲量时业务标识可能正在首次创建号段，需配合 -race 运行
+func TestSegmentBufferedDuringLazyCreate(t *testing.T) {
+	mem := newMemoryAllocator(nil)
+	const tags = 32
+	for i := 0; i < tags; i++ {
+		mem.AddBizTag(fmt.Sprintf("tag-%d", i), 0, 100)
+	}
+	// 拉长首次申请号段的耗时，保证统计时有正在创建的 slot
+	mem.SetLatency(20 * time.Millisecond)
+	s := newTestServer(t, mem, time.Second)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < tags; i++ {
+			wg.Add(1)
+			go func(bizTag string) {
+				defer wg.Done()
+				if _, err := s.segmentBuffer(bizTag); err != nil {
+					t.Errorf("segmentBuffer(%s): %v", bizTag, err)
+				}
+			}(fmt.Sprintf("tag-%d", i))
+		}
+		wg.Wait()
+	}()
+	for {
+		s.segmentBuffered()
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// closeTrackingAllocator 记录 Close 时是否仍有进行中的 Reserve
+type closeTrackingAllocator struct {
+	*memoryAllocator
+	mu               sync.Mutex
+	inflight         int
+	reserves         int
+	closed           bool
+	closedDuringFill bool
+}
+
+func (a *closeTrackingAllocator) Reserve(ctx context.Context, bizTag string, step int64) (int64, error) {
+	a.mu.Lock()
+	a.inflight++
+	a.reserves++
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.inflight--
+		a.mu.Unlock()
+	}()
+	return a.memoryAllocator.Reserve(ctx, bizTag, step)
+}
+
+func (a *closeTrackingAllocator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	a.closedDuringFill = a.inflight > 0
+	return nil
+}
+
+func TestGracefulShutdownWithOpenStream(t *testing.T) {
+	mem := newMemoryAllocator(map[string]int64{defaultBizTag: 100})
+	alloc := &closeTrackingAllocator{memoryAllocator: mem}
+	s := newTestServer(t, alloc, time.Second)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterIDMakerServer(grpcServer, s)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 客户端不主动取消流
+	stream, err := pb.NewIDMakerClient(conn).StreamIDs(context.Background(), &pb.StreamIDsRequest{Mode: "segment", ChunkSize: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	// 流持续消耗号段，存储后端变慢后关闭时总有号段申请在进行中
+	mem.SetLatency(200 * time.Millisecond)
+	streamErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err != nil {
+				streamErr <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(300 * time.Millisecond)
+
+	const timeout = 5 * time.Second
+	start := time.Now()
+	gracefulShutdown(timeout, resources{
+		grpcServer: grpcServer,
+		health:     newHealthChecker(s, health.NewServer(), time.Hour),
+		server:     s,
+		alloc:      alloc,
+	})
+	if elapsed := time.Since(start); elapsed >= timeout {
+		t.Fatalf("shutdown took %v, the open stream held GracefulStop until the deadline", elapsed)
+	}
+
+	if st := status.Convert(<-streamErr); st.Code() != codes.Unavailable || errorReason(st) != reasonShuttingDown {
+		t.Fatalf("stream ended with %v, want Unavailable with %s", st.Err(), reasonShuttingDown)
+	}
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+	if alloc.reserves < 2 {
+		t.Fatalf("only %d reserves, the stream did not keep fills running", alloc.reserves)
+	}
+	if !alloc.closed || alloc.closedDuringFill {
+		t.Fatalf("backend closed = %v, closed during fill = %v, want closed after fills finished", alloc.closed, alloc.closedDuringFill)
+	}
+}
+
+// 存储后端一直不返回时 Shutdown 也要在 ctx 结束时返回
+func TestShutdownHonorsDeadlineWhenBackendHangs(t *testing.T) {
+	mem := newMemoryAllocator(map[string]int64{defaultBizTag: 100})
+	mem.SetLatency(3 * time.Second)
+	s := newTestServer(t, mem, time.Second)
+	if _, err := s.segmentBuffer(defaultBizTag); err != nil {
+		t.Fatal(err)
+	}
+	// 等待 Buffer 的首次填充阻塞在号段申请上
+	segment := s.segments[defaultBizTag].segment
+	waitFor(t, func() bool {
+		segment.mu.Lock()
+		defer segment.mu.Unlock()
+		return segment.load != nil
+	})
+
+	const timeout = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	if err := s.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown succeeded while the segment load was still pending")
+	}
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Fatalf("Shutdown took %v with a %v deadline", elapsed, timeout)
+	}
+}
+
+func TestServerDecodeID(t *testing.T) {
+	s := newTestServer(t, nil, time.Second)
+	id, err := s.snowflake.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := s.snowflake.Layout()
+	tests := []struct {
+		name   string
+		id     int64
+		valid  bool
+		reason string
+	}{
+		{"issued by this node", id, true, ""},
+		{"before epoch", -42, false, "timestamp is before epoch"},
+		{"in the future", layout.compose(layout.ticks(time.Now().Add(24*time.Hour).UnixMilli()), 3, 4, 0), false, "timestamp is in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.DecodeID(context.Background(), &pb.DecodeIDRequest{Id: tt.id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Valid != tt.valid || resp.Reason != tt.reason {
+				t.Fatalf("DecodeID(%d) = valid %v reason %q, want %v %q", tt.id, resp.Valid, resp.Reason, tt.valid, tt.reason)
+			}
+			if tt.valid && (resp.DatacenterId != 3 || resp.MachineId != 4) {
+				t.Fatalf("DecodeID(%d) = %v, want datacenter 3 machine 4", tt.id, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	pbv2 "github.com/mazezen/mid/proto/pb/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// serverV2 mid.v2.IDGenerator 接口的实现，与 v1 接口共用 server 的 Buffer
+type serverV2 struct {
+	pbv2.UnimplementedIDGeneratorServer
+	srv *server
+}
+
+func newServerV2(srv *server) *serverV2 {
+	return &serverV2{srv: srv}
+}
+
+// Generate 生成 count 个 ID
+func (v *serverV2) Generate(ctx context.Context, req *pbv2.GenerateRequest) (*pbv2.GenerateResponse, error) {
+	return v.srv.generate(ctx, req)
+}
+
+// generate v1 和 v2 接口共用的实现
+func (s *server) generate(ctx context.Context, req *pbv2.GenerateRequest) (*pbv2.GenerateResponse, error) {
+	count := int(req.Count)
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count > s.bufferCfg.MaxBatchSize {
+		return nil, invalidArgument("count", "count must be between 1 and %d", s.bufferCfg.MaxBatchSize)
+	}
+	switch req.Generator {
+	case pbv2.Generator_GENERATOR_ULID:
+		return s.generateSortable(ctx, req, s.ulid, s.ulidBuffer, count)
+	case pbv2.Generator_GENERATOR_UUIDV7:
+		return s.generateSortable(ctx, req, s.uuidv7, s.uuidv7Buffer, count)
+	}
+
+	var bizTag string
+	format := pbv2.IntFormat_INT_FORMAT_UNSPECIFIED
+	switch opts := req.Options.(type) {
+	case *pbv2.GenerateRequest_Snowflake:
+		if req.Generator != pbv2.Generator_GENERATOR_SNOWFLAKE {
+			return nil, invalidArgument("snowflake", "snowflake options require GENERATOR_SNOWFLAKE")
+		}
+		format = opts.Snowflake.GetFormat()
+	case *pbv2.GenerateRequest_Segment:
+		if req.Generator != pbv2.Generator_GENERATOR_SEGMENT {
+			return nil, invalidArgument("segment", "segment options require GENERATOR_SEGMENT")
+		}
+		bizTag = opts.Segment.GetBizTag()
+		format = opts.Segment.GetFormat()
+	}
+	if _, ok := pbv2.IntFormat_name[int32(format)]; !ok {
+		return nil, invalidArgument("format", "invalid format %v", format)
+	}
+
+	buffer, err := s.bufferFor(req.Generator, bizTag)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := nextIDs(ctx, buffer, count, s.bufferCfg.WaitTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pbv2.GenerateResponse{Metadata: s.metadata(req.Generator, buffer.bizTag)}
+	if format == pbv2.IntFormat_INT_FORMAT_STRING {
+		resp.IdStrings = make([]string, len(ids))
+		for i, id := range ids {
+			resp.IdStrings[i] = strconv.FormatInt(id, 10)
+		}
+	} else {
+		resp.Ids = ids
+	}
+	return resp, nil
+}
+
+// generateSortable 生成 ULID 或 UUIDv7，同时返回字节和规范字符串
+func (s *server) generateSortable(ctx context.Context, req *pbv2.GenerateRequest, gen *SortableGenerator, buffer *IDBuffer[[16]byte], count int) (*pbv2.GenerateResponse, error) {
+	if req.Options != nil {
+		return nil, invalidArgument("options", "%v does not take options", req.Generator)
+	}
+	ids, err := nextIDs(ctx, buffer, count, s.bufferCfg.WaitTimeout)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pbv2.GenerateResponse{
+		IdBytes:   make([][]byte, len(ids)),
+		IdStrings: make([]string, len(ids)),
+		Metadata:  s.metadata(req.Generator, ""),
+	}
+	for i, id := range ids {
+		resp.IdBytes[i] = id[:]
+		resp.IdStrings[i] = gen.String(id)
+	}
+	return resp, nil
+}
+
+// metadata 响应中的生成器、节点和发放时间
+func (s *server) metadata(generator pbv2.Generator, bizTag string) *pbv2.ResponseMetadata {
+	return &pbv2.ResponseMetadata{
+		Generator: generator,
+		BizTag:    bizTag,
+		NodeId:    s.nodeID(),
+		IssuedAt:  timestamppb.Now(),
+	}
+}
+
+// nodeID 节点标识，数据中心 ID 和机器 ID 在集群内唯一；没有 Snowflake 时为空
+func (s *server) nodeID() string {
+	if s.snowflake == nil {
+		return ""
+	}
+	return strconv.FormatInt(s.snowflake.datacenterID, 10) + "-" + strconv.FormatInt(s.snowflake.machineID, 10)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mazezen/mid/proto/pb"
+	pbv2 "github.com/mazezen/mid/proto/pb/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGenerate(t *testing.T) {
+	alloc := newMemoryAllocator(map[string]int64{defaultBizTag: 1000, "order": 1000})
+	v := newServerV2(newTestServer(t, alloc, time.Second))
+	ctx := context.Background()
+
+	before := time.Now().Add(-time.Second)
+	resp, err := v.Generate(ctx, &pbv2.GenerateRequest{Generator: pbv2.Generator_GENERATOR_SNOWFLAKE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Ids) != 1 || len(resp.IdStrings) != 0 {
+		t.Fatalf("count 0 returned %d ids and %d strings, want 1 id", len(resp.Ids), len(resp.IdStrings))
+	}
+	md := resp.Metadata
+	if md.Generator != pbv2.Generator_GENERATOR_SNOWFLAKE || md.NodeId != "3-4" || md.IssuedAt.AsTime().Before(before) {
+		t.Fatalf("metadata = %v", md)
+	}
+
+	resp, err = v.Generate(ctx, &pbv2.GenerateRequest{
+		Generator: pbv2.Generator_GENERATOR_SEGMENT,
+		Options:   &pbv2.GenerateRequest_Segment{Segment: &pbv2.SegmentOptions{BizTag: "order", Format: pbv2.IntFormat_INT_FORMAT_STRING}},
+		Count:     10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.IdStrings) != 10 || len(resp.Ids) != 0 || resp.Metadata.BizTag != "order" {
+		t.Fatalf("segment response = %v", resp)
+	}
+	var last int64
+	for _, s := range resp.IdStrings {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= last {
+			t.Fatalf("id strings %v are not increasing integers", resp.IdStrings)
+		}
+		last = id
+	}
+
+	// segment 生成器未指定选项时使用默认业务标识
+	resp, err = v.Generate(ctx, &pbv2.GenerateRequest{Generator: pbv2.Generator_GENERATOR_SEGMENT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Metadata.BizTag != defaultBizTag {
+		t.Fatalf("biz_tag = %q, want %q", resp.Metadata.BizTag, defaultBizTag)
+	}
+}
+
+func TestGenerateInvalidArgument(t *testing.T) {
+	v := newServerV2(newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second))
+
+	tests := []struct {
+		name string
+		req  *pbv2.GenerateRequest
+	}{
+		{"unspecified generator", &pbv2.GenerateRequest{}},
+		{"unknown generator", &pbv2.GenerateRequest{Generator: 99}},
+		{"negative count", &pbv2.GenerateRequest{Generator: pbv2.Generator_GENERATOR_SNOWFLAKE, Count: -1}},
+		{"count too large", &pbv2.GenerateRequest{Generator: pbv2.Generator_GENERATOR_SNOWFLAKE, Count: 1001}},
+		{"mismatched options", &pbv2.GenerateRequest{
+			Generator: pbv2.Generator_GENERATOR_SNOWFLAKE,
+			Options:   &pbv2.GenerateRequest_Segment{Segment: &pbv2.SegmentOptions{BizTag: "order"}},
+		}},
+		{"unknown format", &pbv2.GenerateRequest{
+			Generator: pbv2.Generator_GENERATOR_SNOWFLAKE,
+			Options:   &pbv2.GenerateRequest_Snowflake{Snowflake: &pbv2.SnowflakeOptions{Format: 7}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Generate(context.Background(), tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("error = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+// v1 和 v2 接口共用同一组 Buffer，segment ID 在两个接口之间保持递增
+func TestV1SharesV2Buffers(t *testing.T) {
+	s := newTestServer(t, newMemoryAllocator(map[string]int64{defaultBizTag: 1000}), time.Second)
+	v := newServerV2(s)
+	ctx := context.Background()
+
+	var last int64
+	for i := 0; i < 5; i++ {
+		v1, err := s.MakeIDBatch(ctx, &pb.MakeIDBatchRequest{Mode: "segment", Count: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v2, err := v.Generate(ctx, &pbv2.GenerateRequest{Generator: pbv2.Generator_GENERATOR_SEGMENT, Count: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range append(v1.Ids, v2.Ids...) {
+			if id <= last {
+				t.Fatalf("id %d after %d", id, last)
+			}
+			last = id
+		}
+	}
+}
+
+func TestNodeIDWithoutSnowflake(t *testing.T) {
+	s := &server{}
+	if md := s.metadata(pbv2.Generator_GENERATOR_ULID, ""); md.NodeId != "" {
+		t.Fatalf("node id = %q, want empty", md.NodeId)
+	}
+}
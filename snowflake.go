@@ -1,107 +1,235 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-const (
-	epoch          int64 = 1609459200000 // 2021-01-01 00:00:00 UTC
-	timestampBits  = 41
-	datacenterBits = 5
-	machineBits    = 5
-	sequenceBits   = 12
-	maxDatacenter  = -1 ^ (-1 << datacenterBits) // 31
-	maxMachine     = -1 ^ (-1 << machineBits)    // 31
-	maxSequence    = -1 ^ (-1 << sequenceBits)   // 4095
-	sequenceMask   = maxSequence
-	timestampShift = datacenterBits + machineBits + sequenceBits
-	datacenterShift = machineBits + sequenceBits
-	machineShift    = sequenceBits
-)
+// errClockRollback 时钟回退超过允许的漂移范围
+var errClockRollback = errors.New("clock moved backwards beyond drift tolerance")
+
+// errWatermarkStale 当前时间超过最后一次成功持久化的时间戳高水位，按时钟回退处理
+var errWatermarkStale = fmt.Errorf("%w: current timestamp is past the last persisted watermark", errClockRollback)
 
+// errTimestampOverflow 时间戳超出布局的位数，需要调整 epoch 或位布局
+var errTimestampOverflow = errors.New("timestamp overflows layout")
+
+// Snowflake 无锁的 Snowflake 生成器。
+//
+// 最近一次使用的时间戳（相对 epoch 的时间单位数）和该时间单位内最后一个已用序列号
+// 打包在一个 int64 中：时间戳 << SequenceBits | 序列号，通过 CAS 原子更新。
+// 布局保证时间戳位数与序列号位数之和不超过 63，打包后不会溢出。
 type Snowflake struct {
-	mu sync.Mutex
-	lastTimestamp int64
+	layout       Layout
+	state        atomic.Int64
 	datacenterID int64
-	machineID int64
-	sequence int64
-	clockDrift int64 // 允许的时钟漂移（毫秒）
+	machineID    int64
+	clockDrift   int64           // 允许的时钟漂移（时间单位数）
+	registry     *WorkerRegistry // 机器 ID 来自注册表时，租约失效后拒绝生成
+	fence        atomic.Int64    // 最后一次成功持久化的高水位（时间单位数），不生成时间戳超过它的 ID
 }
 
-func NewSnowflake(datacenterID, machineID int64) (*Snowflake, error) {
-	if datacenterID > maxDatacenter ||  machineID > maxMachine {
-		return nil, fmt.Errorf("invalid datacenter or machine id");
+func NewSnowflake(datacenterID, machineID int64, layout Layout) (*Snowflake, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid snowflake layout: %v", err)
+	}
+	if datacenterID < 0 || datacenterID > layout.MaxDatacenter() || machineID < 0 || machineID > layout.MaxMachine() {
+		return nil, fmt.Errorf("invalid datacenter or machine id")
 	}
 	s := &Snowflake{
+		layout:       layout,
 		datacenterID: datacenterID,
-		machineID: machineID,
-		clockDrift: 1000, // 允许 1 秒漂移
+		machineID:    machineID,
+		clockDrift:   max(1000/layout.unitMillis(), 1), // 允许 1 秒漂移
+	}
+	s.fence.Store(math.MaxInt64) // 未持久化高水位时不限制
+	return s, nil
+}
+
+// NewSnowflakeWithRegistry 使用注册表租用的数据中心 ID 和机器 ID 创建 Snowflake
+func NewSnowflakeWithRegistry(registry *WorkerRegistry, layout Layout) (*Snowflake, error) {
+	s, err := NewSnowflake(registry.DatacenterID(), registry.MachineID(), layout)
+	if err != nil {
+		return nil, err
 	}
+	s.registry = registry
 	return s, nil
 }
 
+// Layout 当前使用的位布局
+func (s *Snowflake) Layout() Layout {
+	return s.layout
+}
+
+// unpack 拆出状态中的时间戳（自 Unix 纪元起的时间单位数）和序列号
+func (s *Snowflake) unpack(state int64) (tick, sequence int64) {
+	return (state >> s.layout.SequenceBits) + s.layout.epochTicks(), state & s.layout.maxSequence()
+}
+
+func (s *Snowflake) pack(tick, sequence int64) int64 {
+	return (tick-s.layout.epochTicks())<<s.layout.SequenceBits | sequence
+}
+
+// lastTick 最近一次使用的时间戳（自 Unix 纪元起的时间单位数）
+func (s *Snowflake) lastTick() int64 {
+	tick, _ := s.unpack(s.state.Load())
+	return tick
+}
+
+// LastTimestamp 最近一次生成 ID 使用的时间戳（Unix 毫秒）
+func (s *Snowflake) LastTimestamp() int64 {
+	return s.lastTick() * s.layout.unitMillis()
+}
+
+// restoreLastTimestamp 将 lastTimestamp 恢复为持久化的高水位，最多等待 wait 让时钟越过高水位。
+// 等待超时后时钟仍落后时，NextID 按时钟回退处理，拒绝发号直到时钟追上
+func (s *Snowflake) restoreLastTimestamp(markMillis int64, wait time.Duration) {
+	mark := s.layout.ticks(markMillis)
+	deadline := time.Now().Add(wait)
+	for s.getTimestamp() <= mark && time.Now().Before(deadline) {
+		time.Sleep(min(time.Until(deadline), 10*time.Millisecond))
+	}
+
+	for {
+		old := s.state.Load()
+		if last, _ := s.unpack(old); mark < last {
+			break
+		}
+		// 高水位所在时间单位的序列号可能已用过，强制等待下一个时间单位
+		if s.state.CompareAndSwap(old, s.pack(mark, s.layout.maxSequence())) {
+			break
+		}
+	}
 
-// getTimestamp 获取当前时间戳
+	if now := s.getTimestamp(); now <= mark {
+		mLog.Warn("Clock is still behind snowflake watermark, refusing IDs until it catches up",
+			zap.Int64("watermark", mark),
+			zap.Int64("current_timestamp", now))
+	}
+}
+
+// setFence 设置已持久化的高水位（Unix 毫秒），之后只生成时间戳不超过它的 ID
+func (s *Snowflake) setFence(markMillis int64) {
+	s.fence.Store(s.layout.ticks(markMillis))
+}
+
+// Healthy 检查能否继续生成 ID：租约有效、时钟没有回退超过允许的漂移范围且没有越过已持久化的高水位
+func (s *Snowflake) Healthy() error {
+	if s.registry != nil && !s.registry.Valid() {
+		return errLeaseLost
+	}
+	now := s.getTimestamp()
+	if s.lastTick()-now > s.clockDrift {
+		return errClockRollback
+	}
+	if now > s.fence.Load() {
+		return errWatermarkStale
+	}
+	return nil
+}
+
+// getTimestamp 获取当前时间戳（自 Unix 纪元起的时间单位数）
 func (s *Snowflake) getTimestamp() int64 {
-	return time.Now().UnixMilli()
+	return s.layout.ticks(time.Now().UnixMilli())
+}
+
+// sleepUntilAfter 睡眠到时间单位 tick 结束
+func (s *Snowflake) sleepUntilAfter(tick int64) {
+	time.Sleep(time.Until(time.UnixMilli((tick + 1) * s.layout.unitMillis())))
 }
 
 func (s *Snowflake) NextID() (int64, error) {
+	var id int64
+	if err := s.reserve(1, func(tick, first, count int64) {
+		id = s.layout.compose(tick, s.datacenterID, s.machineID, first)
+	}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// NextN 生成 n 个递增的 ID。每次 CAS 预留当前时间单位内剩余的一段序列号，
+// n 超过一个时间单位的容量时跨多个时间单位
+func (s *Snowflake) NextN(n int) ([]int64, error) {
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		if err := s.reserve(int64(n-len(ids)), func(tick, first, count int64) {
+			for seq := first; seq < first+count; seq++ {
+				ids = append(ids, s.layout.compose(tick, s.datacenterID, s.machineID, seq))
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// reserve 在一个时间单位内原子地预留最多 n 个连续序列号，预留成功后调用 emit
+func (s *Snowflake) reserve(n int64, emit func(tick, first, count int64)) error {
+	if s.registry != nil && !s.registry.Valid() {
+		return errLeaseLost
+	}
+	maxSequence := s.layout.maxSequence()
 	for {
+		old := s.state.Load()
+		last, lastSeq := s.unpack(old)
 		timestamp := s.getTimestamp()
 
-		// 处理时钟回退（无锁）
-		if timestamp < s.lastTimestamp {
-			if s.lastTimestamp-timestamp <= s.clockDrift {
-				for timestamp <= s.lastTimestamp {
-					time.Sleep(time.Microsecond * 100)
-					timestamp = s.getTimestamp()
-				}
-			} else {
+		// 处理时钟回退：漂移范围内等待时钟追上，超出则拒绝
+		if timestamp < last {
+			if last-timestamp > s.clockDrift {
 				mLog.Error("Clock moved backwards beyond drift tolerance",
-					zap.Int64("last_timestamp", s.lastTimestamp),
+					zap.Int64("last_timestamp", last),
 					zap.Int64("current_timestamp", timestamp))
-				return 0, fmt.Errorf("clock moved backwards beyond drift tolerance")
+				return errClockRollback
 			}
+			s.sleepUntilAfter(last - 1)
+			continue
 		}
 
-		s.mu.Lock()
+		// 高水位保存失败时，重启后无法保证不重复，拒绝继续发号
+		if timestamp > s.fence.Load() {
+			return errWatermarkStale
+		}
 
-		// 再次检查时间戳
-		if timestamp < s.lastTimestamp {
-			s.mu.Unlock()
-			continue
+		// 时间戳位数用尽
+		if timestamp-s.layout.epochTicks() > s.layout.maxTimestamp() {
+			return fmt.Errorf("%w: %d timestamp bits", errTimestampOverflow, s.layout.TimestampBits)
 		}
 
-		// 序列号处理
-		if timestamp == s.lastTimestamp {
-			s.sequence = (s.sequence + 1) & sequenceMask
-			if s.sequence == 0 {
-				// 序列号用尽，释放锁后等待
-				s.mu.Unlock()
-				for timestamp <= s.lastTimestamp {
-					time.Sleep(time.Microsecond * 100)
-					timestamp = s.getTimestamp()
-				}
+		first := int64(0)
+		if timestamp == last {
+			if lastSeq == maxSequence {
+				// 序列号用尽，等待下一个时间单位
+				s.sleepUntilAfter(last)
 				continue
 			}
-		} else {
-			s.sequence = 0
+			first = lastSeq + 1
 		}
+		count := min(n, maxSequence-first+1)
+		if s.state.CompareAndSwap(old, s.pack(timestamp, first+count-1)) {
+			emit(timestamp, first, count)
+			return nil
+		}
+	}
+}
 
-		s.lastTimestamp = timestamp
-
-		// 生成 ID
-		id := ((timestamp - epoch) << timestampShift) |
-			(s.datacenterID << datacenterShift) |
-			(s.machineID << machineShift) |
-			s.sequence
+// SnowflakeParts Snowflake ID 拆解后的各个部分
+type SnowflakeParts struct {
+	Timestamp    int64  // 生成时间（Unix 毫秒）
+	DatacenterID int64  // 数据中心 ID
+	MachineID    int64  // 机器 ID
+	Sequence     int64  // 时间单位内的序列号
+	Valid        bool   // 时间戳不早于 epoch 且不晚于当前时间
+	Reason       string // Valid 为 false 时的原因
+}
 
-		s.mu.Unlock()
-		return id, nil
-	}
-}
\ No newline at end of file
+// DecodeID 按默认布局拆解 Snowflake ID
+func DecodeID(id int64) SnowflakeParts {
+	return DefaultLayout.Decode(id)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestSnowflake(t testing.TB, layout Layout) *Snowflake {
+	t.Helper()
+	mLog = zap.NewNop()
+	s, err := NewSnowflake(1, 2, layout)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	return s
+}
+
+func TestSnowflakeConcurrentUnique(t *testing.T) {
+	// 序列号只有 4 位，频繁触发序列号用尽后的等待
+	layout := DefaultLayout
+	layout.SequenceBits = 4
+	s := newTestSnowflake(t, layout)
+
+	const workers, perWorker = 8, 200
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool, workers*perWorker*2)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(batch bool) {
+			defer wg.Done()
+			var ids []int64
+			for j := 0; j < perWorker; j++ {
+				if batch {
+					got, err := s.NextN(3)
+					if err != nil {
+						t.Errorf("NextN: %v", err)
+						return
+					}
+					ids = append(ids, got...)
+				} else {
+					id, err := s.NextID()
+					if err != nil {
+						t.Errorf("NextID: %v", err)
+						return
+					}
+					ids = append(ids, id)
+				}
+			}
+			for k := 1; k < len(ids); k++ {
+				if ids[k] <= ids[k-1] {
+					t.Errorf("id %d not greater than previous %d", ids[k], ids[k-1])
+					return
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestSnowflakeNextNSpansTicks(t *testing.T) {
+	layout := DefaultLayout
+	layout.SequenceBits = 4
+	s := newTestSnowflake(t, layout)
+
+	ids, err := s.NextN(40)
+	if err != nil {
+		t.Fatalf("NextN: %v", err)
+	}
+	if len(ids) != 40 {
+		t.Fatalf("NextN returned %d ids, want 40", len(ids))
+	}
+	ticks := make(map[int64]int)
+	for i, id := range ids {
+		parts := layout.Decode(id)
+		if parts.DatacenterID != 1 || parts.MachineID != 2 {
+			t.Fatalf("id %d decodes to datacenter %d machine %d", id, parts.DatacenterID, parts.MachineID)
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Fatalf("id %d not greater than previous %d", id, ids[i-1])
+		}
+		ticks[parts.Timestamp]++
+	}
+	// 每个时间单位最多 16 个序列号，40 个 ID 至少跨 3 个时间单位
+	if len(ticks) < 3 {
+		t.Fatalf("40 ids used %d ticks, want at least 3", len(ticks))
+	}
+}
+
+func TestSnowflakeRestoreWatermark(t *testing.T) {
+	s := newTestSnowflake(t, DefaultLayout)
+	mark := time.Now().UnixMilli() + 20
+	s.restoreLastTimestamp(mark, time.Second)
+	id, err := s.NextID()
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	if ts := DecodeID(id).Timestamp; ts <= mark {
+		t.Fatalf("id timestamp %d not after watermark %d", ts, mark)
+	}
+
+	// 时钟落后高水位超过允许的漂移时拒绝发号
+	s = newTestSnowflake(t, DefaultLayout)
+	s.restoreLastTimestamp(time.Now().UnixMilli()+time.Hour.Milliseconds(), 0)
+	if _, err := s.NextID(); err != errClockRollback {
+		t.Fatalf("NextID error = %v, want errClockRollback", err)
+	}
+	if err := s.Healthy(); err != errClockRollback {
+		t.Fatalf("Healthy = %v, want errClockRollback", err)
+	}
+}
+
+func BenchmarkSnowflakeNextID(b *testing.B) {
+	s := newTestSnowflake(b, DefaultLayout)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.NextID(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSnowflakeNextN(b *testing.B) {
+	s := newTestSnowflake(b, DefaultLayout)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.NextN(100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// sortableKind 128 位可排序 ID 的格式
+type sortableKind int
+
+const (
+	kindULID   sortableKind = iota // https://github.com/ulid/spec，随机部分 80 位
+	kindUUIDv7                     // RFC 9562 UUID version 7，随机部分 74 位
+)
+
+// crockfordAlphabet ULID 使用的 Crockford Base32 字母表，按 ASCII 递增，字符串顺序与字节顺序一致
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// SortableGenerator 生成 ULID 或 UUIDv7：48 位毫秒时间戳加随机数的 128 位 ID，无需节点间协调。
+//
+// 每个毫秒的第一个 ID 使用新的随机数，同一毫秒内随机部分逐个加 1（RFC 9562 6.2 节方法 2），
+// 保证同一节点生成的 ID 按字节序和字符串序严格递增。时钟回退时沿用上一个时间戳继续递增，
+// 随机部分溢出时借用下一毫秒
+type SortableGenerator struct {
+	kind     sortableKind
+	randBits uint // 随机部分位数
+
+	mu     sync.Mutex
+	lastMs int64
+	hi     uint64 // 随机部分的高 randBits-64 位
+	lo     uint64 // 随机部分的低 64 位
+
+	now  func() int64 // 当前 Unix 毫秒，测试中可替换
+	rand io.Reader
+}
+
+func NewSortableGenerator(kind sortableKind) *SortableGenerator {
+	g := &SortableGenerator{
+		kind: kind,
+		now:  func() int64 { return time.Now().UnixMilli() },
+		rand: rand.Reader,
+	}
+	switch kind {
+	case kindULID:
+		g.randBits = 80
+	case kindUUIDv7:
+		g.randBits = 74
+	}
+	return g
+}
+
+// NextN 生成 n 个递增的 ID
+func (g *SortableGenerator) NextN(n int) ([][16]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ids := make([][16]byte, n)
+	for i := range ids {
+		if ms := g.now(); ms > g.lastMs {
+			if err := g.reseed(); err != nil {
+				return nil, err
+			}
+			g.lastMs = ms
+		} else if !g.increment() {
+			// 随机部分用尽，借用下一毫秒
+			if err := g.reseed(); err != nil {
+				return nil, err
+			}
+			g.lastMs++
+		}
+		ids[i] = g.compose()
+	}
+	return ids, nil
+}
+
+// reseed 为新的毫秒生成随机部分
+func (g *SortableGenerator) reseed() error {
+	var buf [16]byte
+	if _, err := io.ReadFull(g.rand, buf[:]); err != nil {
+		return fmt.Errorf("read random bytes: %w", err)
+	}
+	g.hi = binary.BigEndian.Uint64(buf[:8]) & (1<<(g.randBits-64) - 1)
+	g.lo = binary.BigEndian.Uint64(buf[8:])
+	return nil
+}
+
+// increment 随机部分加 1，溢出时返回 false
+func (g *SortableGenerator) increment() bool {
+	g.lo++
+	if g.lo != 0 {
+		return true
+	}
+	g.hi++
+	return g.hi < 1<<(g.randBits-64)
+}
+
+// compose 按格式拼接时间戳和随机部分
+func (g *SortableGenerator) compose() [16]byte {
+	var id [16]byte
+	ms := uint64(g.lastMs)
+	id[0], id[1], id[2], id[3], id[4], id[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	switch g.kind {
+	case kindULID:
+		binary.BigEndian.PutUint16(id[6:8], uint16(g.hi))
+		binary.BigEndian.PutUint64(id[8:], g.lo)
+	case kindUUIDv7:
+		// 74 位随机部分拆成 12 位 rand_a 和 62 位 rand_b，中间插入版本号 0111 和变体 10
+		randA := g.hi<<2 | g.lo>>62
+		binary.BigEndian.PutUint16(id[6:8], 0x7000|uint16(randA))
+		binary.BigEndian.PutUint64(id[8:], 1<<63|g.lo&(1<<62-1))
+	}
+	return id
+}
+
+// String 返回 ID 的规范字符串：ULID 为 26 位 Crockford Base32，UUIDv7 为 8-4-4-4-12 格式的小写十六进制
+func (g *SortableGenerator) String(id [16]byte) string {
+	if g.kind == kindULID {
+		return ulidString(id)
+	}
+	return uuidString(id)
+}
+
+func ulidString(id [16]byte) string {
+	// 128 位高位补 2 个 0 位，共 130 位，每 5 位一个字符
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+func uuidString(id [16]byte) string {
+	var out [36]byte
+	hex.Encode(out[0:8], id[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], id[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], id[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], id[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], id[10:])
+	return string(out[:])
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	pbv2 "github.com/mazezen/mid/proto/pb/v2"
+)
+
+// fixedClock 可手动设置的毫秒时钟
+type fixedClock struct {
+	mu sync.Mutex
+	ms int64
+}
+
+func (c *fixedClock) now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ms
+}
+
+func (c *fixedClock) set(ms int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ms = ms
+}
+
+func newTestSortable(kind sortableKind, clock *fixedClock) *SortableGenerator {
+	g := NewSortableGenerator(kind)
+	g.now = clock.now
+	return g
+}
+
+func idTimestamp(id [16]byte) int64 {
+	return int64(binary.BigEndian.Uint64(append([]byte{0, 0}, id[:6]...)))
+}
+
+func TestSortableMonotonicWithinMillisecond(t *testing.T) {
+	for _, kind := range []sortableKind{kindULID, kindUUIDv7} {
+		clock := &fixedClock{ms: 1700000000000}
+		g := newTestSortable(kind, clock)
+
+		ids, err := g.NextN(1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 时钟回退后继续递增
+		clock.set(1699999999000)
+		more, err := g.NextN(1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, more...)
+
+		for i, id := range ids {
+			if ts := idTimestamp(id); ts != 1700000000000 {
+				t.Fatalf("kind %d: id %d has timestamp %d", kind, i, ts)
+			}
+			if i == 0 {
+				continue
+			}
+			if bytes.Compare(ids[i-1][:], id[:]) >= 0 {
+				t.Fatalf("kind %d: id %d is not greater than the previous one", kind, i)
+			}
+			if g.String(ids[i-1]) >= g.String(id) {
+				t.Fatalf("kind %d: string %s is not greater than %s", kind, g.String(id), g.String(ids[i-1]))
+			}
+		}
+	}
+}
+
+func TestSortableOverflowBorrowsNextMillisecond(t *testing.T) {
+	clock := &fixedClock{ms: 1700000000000}
+	g := newTestSortable(kindUUIDv7, clock)
+	if _, err := g.NextN(1); err != nil {
+		t.Fatal(err)
+	}
+	// 随机部分已是最大值
+	g.hi, g.lo = 1<<10-1, ^uint64(0)
+
+	ids, err := g.NextN(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := idTimestamp(ids[0]); ts != 1700000000001 {
+		t.Fatalf("timestamp after overflow = %d, want 1700000000001", ts)
+	}
+	if bytes.Compare(ids[0][:], ids[1][:]) >= 0 {
+		t.Fatal("ids after overflow are not increasing")
+	}
+}
+
+func TestUUIDv7Format(t *testing.T) {
+	g := NewSortableGenerator(kindUUIDv7)
+	ids, err := g.NextN(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, id := range ids {
+		if s := g.String(id); !pattern.MatchString(s) {
+			t.Fatalf("%s is not a canonical UUIDv7", s)
+		}
+	}
+	if ts := idTimestamp(ids[0]); time.Since(time.UnixMilli(ts)) > time.Minute {
+		t.Fatalf("timestamp %d is not current", ts)
+	}
+}
+
+func TestULIDString(t *testing.T) {
+	tests := []struct {
+		id   [16]byte
+		want string
+	}{
+		{[16]byte{}, "00000000000000000000000000"},
+		{[16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		// ulid 规范中的示例：时间戳 1469918176385
+		{[16]byte{0x01, 0x56, 0x3d, 0xf3, 0x64, 0x81}, "01ARYZ6S41" + "0000000000000000"},
+	}
+	for _, tt := range tests {
+		if got := ulidString(tt.id); got != tt.want {
+			t.Fatalf("ulidString(%x) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSortable(t *testing.T) {
+	v := newServerV2(newTestServer(t, nil, time.Second))
+	ctx := context.Background()
+
+	for _, generator := range []pbv2.Generator{pbv2.Generator_GENERATOR_ULID, pbv2.Generator_GENERATOR_UUIDV7} {
+		resp, err := v.Generate(ctx, &pbv2.GenerateRequest{Generator: generator, Count: 500})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.IdBytes) != 500 || len(resp.IdStrings) != 500 || len(resp.Ids) != 0 {
+			t.Fatalf("%v: got %d bytes, %d strings, %d ints", generator, len(resp.IdBytes), len(resp.IdStrings), len(resp.Ids))
+		}
+		if resp.Metadata.Generator != generator {
+			t.Fatalf("metadata generator = %v, want %v", resp.Metadata.Generator, generator)
+		}
+		for i := 1; i < len(resp.IdBytes); i++ {
+			if len(resp.IdBytes[i]) != 16 || bytes.Compare(resp.IdBytes[i-1], resp.IdBytes[i]) >= 0 || resp.IdStrings[i-1] >= resp.IdStrings[i] {
+				t.Fatalf("%v: ids are not increasing at %d", generator, i)
+			}
+		}
+
+		_, err = v.Generate(ctx, &pbv2.GenerateRequest{
+			Generator: generator,
+			Options:   &pbv2.GenerateRequest_Segment{Segment: &pbv2.SegmentOptions{}},
+		})
+		if err == nil {
+			t.Fatalf("%v with segment options should fail", generator)
+		}
+	}
+}
+
+func BenchmarkSortableNextN(b *testing.B) {
+	g := NewSortableGenerator(kindUUIDv7)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := g.NextN(1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
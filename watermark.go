@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// timestampStore 持久化 Snowflake 已使用的时间戳高水位（Unix 毫秒）
+type timestampStore interface {
+	Load() (int64, error)
+	Save(ctx context.Context, ts int64) error
+}
+
+// fileTimestampStore 将高水位保存在本地文件中
+type fileTimestampStore struct {
+	path string
+}
+
+func (f *fileTimestampStore) Load() (int64, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	ts, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watermark file %s: %v", f.path, err)
+	}
+	return ts, nil
+}
+
+// Save 先写临时文件并 fsync，再原子替换，避免崩溃时留下半个文件
+func (f *fileTimestampStore) Save(ctx context.Context, ts int64) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(strconv.FormatInt(ts, 10) + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// watermark 定期持久化 Snowflake 的时间戳高水位
+//
+// 运行期间保存的是 当前时间 + 2 个保存间隔，保证进程崩溃前发出的 ID 都不超过已保存的高水位；
+// 正常关闭时保存精确的 lastTimestamp。重启后等待时钟越过高水位再发号，
+// 防止主机时钟落后时与重启前发出的 ID 重复。
+//
+// 只有所有存储都保存成功的高水位才会设置为 Snowflake 的上限，保存持续失败时时钟越过上限后拒绝发号。
+type watermark struct {
+	snowflake *Snowflake
+	stores    []timestampStore
+	interval  time.Duration
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newWatermark(snowflake *Snowflake, interval time.Duration, stores ...timestampStore) *watermark {
+	// 第一次保存成功之前不发号
+	snowflake.setFence(0)
+	return &watermark{
+		snowflake: snowflake,
+		stores:    stores,
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+}
+
+// Restore 读取所有存储中最大的高水位，最多等待 wait 让时钟越过高水位
+func (w *watermark) Restore(wait time.Duration) error {
+	var mark int64
+	for _, store := range w.stores {
+		ts, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load watermark: %v", err)
+		}
+		mark = max(mark, ts)
+	}
+	if mark == 0 {
+		return nil
+	}
+	mLog.Info("Restoring snowflake watermark",
+		zap.Int64("watermark", mark),
+		zap.Int64("behind_ms", mark-time.Now().UnixMilli()))
+	w.snowflake.restoreLastTimestamp(mark, wait)
+	return nil
+}
+
+// Start 同步保存一次高水位后启动定期保存
+func (w *watermark) Start() {
+	w.save(w.next())
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			w.save(w.next())
+		}
+	}()
+}
+
+// next 运行期间保存的高水位
+func (w *watermark) next() int64 {
+	return time.Now().UnixMilli() + 2*w.interval.Milliseconds()
+}
+
+// save 将高水位写入所有存储，每次最多等待一个保存间隔；全部成功后才推进 Snowflake 的上限
+func (w *watermark) save(ts int64) {
+	ts = max(ts, w.snowflake.LastTimestamp())
+	ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+	defer cancel()
+	for _, store := range w.stores {
+		if err := store.Save(ctx, ts); err != nil {
+			mLog.Error("Failed to save snowflake watermark", zap.Int64("watermark", ts), zap.Error(err))
+			return
+		}
+	}
+	w.snowflake.setFence(ts)
+}
+
+// Close 停止定期保存，并保存精确的 lastTimestamp
+func (w *watermark) Close() {
+	close(w.done)
+	w.wg.Wait()
+	w.save(0)
+}
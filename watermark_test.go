@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileTimestampStore(t *testing.T) {
+	store := &fileTimestampStore{path: filepath.Join(t.TempDir(), "data", "snowflake.watermark")}
+	if ts, err := store.Load(); err != nil || ts != 0 {
+		t.Fatalf("Load missing file = %d, %v, want 0", ts, err)
+	}
+	for _, want := range []int64{1700000000000, 1700000001234} {
+		if err := store.Save(context.Background(), want); err != nil {
+			t.Fatal(err)
+		}
+		if ts, err := store.Load(); err != nil || ts != want {
+			t.Fatalf("Load = %d, %v, want %d", ts, err, want)
+		}
+	}
+	if _, err := os.Stat(store.path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	if err := os.WriteFile(store.path, []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); err == nil {
+		t.Fatal("Load accepted an invalid watermark file")
+	}
+}
+
+// stubTimestampStore 可注入失败和阻塞的高水位存储
+type stubTimestampStore struct {
+	mu    sync.Mutex
+	mark  int64
+	err   error
+	block bool // Save 阻塞到 ctx 结束
+	saves int
+}
+
+func (s *stubTimestampStore) Load() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mark, nil
+}
+
+func (s *stubTimestampStore) Save(ctx context.Context, ts int64) error {
+	s.mu.Lock()
+	s.saves++
+	err, block := s.err, s.block
+	s.mu.Unlock()
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mark = ts
+	return nil
+}
+
+func (s *stubTimestampStore) set(err error, block bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err, s.block = err, block
+}
+
+func TestWatermarkRestoreWaitsForClock(t *testing.T) {
+	snowflake := newTestSnowflake(t, DefaultLayout)
+	mark := time.Now().UnixMilli() + 200
+	w := newWatermark(snowflake, time.Second, &stubTimestampStore{mark: mark})
+
+	start := time.Now()
+	if err := w.Restore(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now().UnixMilli(); now <= mark {
+		t.Fatalf("Restore returned at %d before the clock passed watermark %d", now, mark)
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Fatalf("Restore waited %v, want about 200ms", elapsed)
+	}
+	w.Start()
+	defer w.Close()
+	id, err := snowflake.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := DecodeID(id).Timestamp; ts <= mark {
+		t.Fatalf("id timestamp %d not after watermark %d", ts, mark)
+	}
+}
+
+func TestWatermarkRestoreRefusesWhenClockBehind(t *testing.T) {
+	snowflake := newTestSnowflake(t, DefaultLayout)
+	w := newWatermark(snowflake, time.Second, &stubTimestampStore{mark: time.Now().UnixMilli() + time.Hour.Milliseconds()})
+
+	start := time.Now()
+	if err := w.Restore(50 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Restore waited %v past startup_wait", elapsed)
+	}
+	w.Start()
+	defer w.Close()
+	if _, err := snowflake.NextID(); !errors.Is(err, errClockRollback) {
+		t.Fatalf("NextID error = %v, want errClockRollback", err)
+	}
+}
+
+func TestWatermarkFencesUnsavedTimestamps(t *testing.T) {
+	snowflake := newTestSnowflake(t, DefaultLayout)
+	store := &stubTimestampStore{err: errors.New("disk full")}
+	w := newWatermark(snowflake, 20*time.Millisecond, store)
+	if err := w.Restore(0); err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+	defer w.Close()
+
+	// 从未保存成功，不发号
+	if _, err := snowflake.NextID(); !errors.Is(err, errWatermarkStale) {
+		t.Fatalf("NextID before any save = %v, want errWatermarkStale", err)
+	}
+	if err := snowflake.Healthy(); !errors.Is(err, errClockRollback) {
+		t.Fatalf("Healthy before any save = %v, want a clock rollback error", err)
+	}
+
+	store.set(nil, false)
+	waitFor(t, func() bool { _, err := snowflake.NextID(); return err == nil })
+
+	// 保存阻塞时在一个保存间隔后超时，时钟越过最后保存的高水位后拒绝发号
+	store.set(nil, true)
+	waitFor(t, func() bool { _, err := snowflake.NextID(); return errors.Is(err, errWatermarkStale) })
+	id, err := snowflake.NextID()
+	if err == nil {
+		t.Fatalf("NextID issued %d past the persisted watermark %d", id, mustLoad(t, store))
+	}
+	if last, saved := snowflake.LastTimestamp(), mustLoad(t, store); last > saved {
+		t.Fatalf("last timestamp %d is past the persisted watermark %d", last, saved)
+	}
+}
+
+func mustLoad(t *testing.T, store timestampStore) int64 {
+	t.Helper()
+	ts, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// waitFor 每 10ms 检查一次 cond，最多等待 2 秒
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}